@@ -19,7 +19,6 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 )
 
@@ -83,6 +82,10 @@ type Account interface {
 	// Transfer sends the specified value of Eth to the given address.
 	Transfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, confirmBlocks int64, sendAll bool) (*types.Transaction, error)
 
+	// TransferWithOptions sends the specified value of Eth to the given
+	// address, as configured by the options.
+	TransferWithOptions(ctx context.Context, to common.Address, value *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error)
+
 	ContractTransactCtor(ctx context.Context, contractAddress common.Address, fnName string, params ...[]byte) (func(transactOpts *bind.TransactOpts) (*types.Transaction, error), error)
 
 	// Transact performs a write operation on the Ethereum blockchain. It will
@@ -93,13 +96,47 @@ type Account interface {
 	// returned from ethereum.
 	Transact(ctx context.Context, speed TxExecutionSpeed, preConditionCheck func() bool, f func(*bind.TransactOpts) (*types.Transaction, error), postConditionCheck func() bool, confirmBlocks int64) (*types.Transaction, error)
 
-	// Sign the given message with the account's private key.
+	// TransactWithOptions performs a write operation on the Ethereum
+	// blockchain like Transact, with the value, gas, nonce, retries and
+	// timeouts configured by the options.
+	TransactWithOptions(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error)
+
+	// Prepare builds an unsigned transaction envelope from 'f' instead of
+	// sending the transaction, so that it can be signed by an OfflineAccount.
+	Prepare(ctx context.Context, speed TxExecutionSpeed, f func(*bind.TransactOpts) (*types.Transaction, error)) (*UnsignedTx, error)
+
+	// PrepareTransfer builds an unsigned transaction envelope that transfers
+	// the specified value of Eth to the given address.
+	PrepareTransfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, sendAll bool) (*UnsignedTx, error)
+
+	// Sign the given message with the account's private key. Accounts with a
+	// spending policy only sign hashes if the policy allows raw signing.
 	Sign(msgHash []byte) ([]byte, error)
 
+	// SignPersonalMessage signs the EIP-191 hash of the message with the
+	// account's private key.
+	SignPersonalMessage(msg []byte) ([]byte, error)
+
+	// SignTypedData signs the EIP-712 digest of the typed data with the
+	// account's private key.
+	SignTypedData(typedData TypedData) ([]byte, error)
+
+	// Signer returns the signer that holds the account's key.
+	Signer() Signer
+
 	// SetGasPrice allows the account holder to set the gasPrice to a specific
 	// value.
 	SetGasPrice(gasPrice float64)
 
+	// RepairNonces compares the local, pending and latest nonces of the
+	// account, and fills the gaps between them. Nonces of prepared envelopes
+	// are never filled.
+	RepairNonces(ctx context.Context) (NonceReport, error)
+
+	// MonitorNonces repairs the nonces of the account on every interval until
+	// the context is done, sending reports of gaps and errors to the channel.
+	MonitorNonces(ctx context.Context, interval time.Duration, reports chan<- NonceReport) error
+
 	// ResetToPendingNonce will wait for a 'coolDown' time (in milliseconds)
 	// before updating transaction nonce to current pending nonce.
 	ResetToPendingNonce(ctx context.Context, coolDown time.Duration) error
@@ -108,7 +145,13 @@ type Account interface {
 	// the transaction can be viewed.
 	FormatTransactionView(msg, txHash string) (string, error)
 
-	NewERC20(addressOrAlias string) (ERC20, error)
+	// NewERC20, NewNFT721 and NewToken1155 return token contracts whose
+	// write operations are sent from the account. With the Validate option,
+	// the contract is inspected first, and rejected if it does not implement
+	// the standard.
+	NewERC20(addressOrAlias string, options ...ContractOption) (ERC20, error)
+	NewNFT721(addressOrAlias string, options ...ContractOption) (NFT721, error)
+	NewToken1155(addressOrAlias string, options ...ContractOption) (Token1155, error)
 }
 
 type account struct {
@@ -118,18 +161,46 @@ type account struct {
 	callOpts     *bind.CallOpts
 	transactOpts *bind.TransactOpts
 
-	privateKey  *ecdsa.PrivateKey
+	signer      Signer
 	addressBook AddressBook
+
+	// inFlight maps the nonces of transactions that have been broadcast, but
+	// not yet mined, to their hashes. Superseded maps the hashes of
+	// transactions that were re-sequenced behind a dropped transaction to the
+	// transactions that replaced them.
+	inFlight   map[uint64]common.Hash
+	superseded map[common.Hash]*types.Transaction
+
+	// journal holds the transactions that have been signed, by nonce, until
+	// their nonce is final. Prepared holds the nonces of envelopes that have
+	// been prepared, but might not have been broadcast yet.
+	journal  map[uint64]*types.Transaction
+	prepared map[uint64]bool
+
+	// filling holds the nonces that are being filled or re-sequenced, while
+	// the transactions for them are signed and sent without holding the
+	// mutex, so that they are only filled once.
+	filling map[uint64]bool
 }
 
 // NewAccount returns a user account for the provided private key which is
 // connected to an Ethereum client.
 func NewAccount(client Client, privateKey *ecdsa.PrivateKey) (Account, error) {
+	return NewAccountWithSigner(client, NewPrivateKeySigner(privateKey))
+}
+
+// NewAccountWithSigner returns a user account for the provided signer which is
+// connected to an Ethereum client. The account never has access to the key
+// itself, all signing is delegated to the signer.
+func NewAccountWithSigner(client Client, signer Signer) (Account, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
 
-	// Setup transact opts
-	transactOpts := bind.NewKeyedTransactor(privateKey)
+	// Setup transact opts, whose signer is set for every transaction so that
+	// signing is bound to the transaction's context
+	transactOpts := &bind.TransactOpts{
+		From: signer.Address(),
+	}
 	nonce, err := client.EthClient().PendingNonceAt(ctx, transactOpts.From)
 	if err != nil {
 		return nil, err
@@ -142,13 +213,29 @@ func NewAccount(client Client, privateKey *ecdsa.PrivateKey) (Account, error) {
 		client:       client,
 		callOpts:     new(bind.CallOpts),
 		transactOpts: transactOpts,
-		privateKey:   privateKey,
+		signer:       signer,
 		addressBook:  NetworkAddressBook(client.renNetwork),
+		inFlight:     map[uint64]common.Hash{},
+		superseded:   map[common.Hash]*types.Transaction{},
+		journal:      map[uint64]*types.Transaction{},
+		prepared:     map[uint64]bool{},
+		filling:      map[uint64]bool{},
 	}
 
 	return account, nil
 }
 
+// signerFn returns a function that signs transactions of the account with its
+// signer under the context.
+func (account *account) signerFn(ctx context.Context) bind.SignerFn {
+	return func(txSigner types.Signer, address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		if address != account.signer.Address() {
+			return nil, errors.New("not authorized to sign this account")
+		}
+		return account.signer.SignTx(ctx, txSigner, tx)
+	}
+}
+
 // Address returns the ethereum address of the account.
 func (account *account) Address() common.Address {
 	return account.transactOpts.From
@@ -193,19 +280,41 @@ func (account *account) EthClient() *ethclient.Client {
 // error, or if given context times-out, or if ErrReplaceUnderpriced is
 // returned from Ethereum.
 func (account *account) Transact(ctx context.Context, speed TxExecutionSpeed, preConditionCheck func() bool, f func(*bind.TransactOpts) (*types.Transaction, error), postConditionCheck func() bool, waitForBlocks int64) (*types.Transaction, error) {
+	options := DefaultTransactOptions(speed, waitForBlocks)
+	options.PreConditionCheck = preConditionCheck
+	options.PostConditionCheck = postConditionCheck
+	return account.TransactWithOptions(ctx, f, options)
+}
+
+// TransactWithOptions attempts to execute a transaction on the Ethereum
+// blockchain with the retry functionality, as configured by the options.
+// Every transaction that is signed for it belongs to the same operation, so
+// that a spending policy only counts the last of them.
+func (account *account) TransactWithOptions(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error) {
+	options = options.withDefaults()
+	ctx = withOperation(ctx)
+
 	// Do not proceed any further if the (not nil) pre-condition check fails
-	if preConditionCheck != nil && !preConditionCheck() {
+	if options.PreConditionCheck != nil && !options.PreConditionCheck() {
 		return nil, ErrPreConditionCheckFailed
 	}
+	if options.PreCondition != nil {
+		if ok, err := CheckCondition(ctx, account.client, options.PreCondition, nil); err != nil || !ok {
+			return nil, ErrPreConditionCheckFailed
+		}
+	}
 
-	sleepDurationMs := time.Duration(1000)
+	sleepDuration := options.Retry.InitialDelay
 	var txHash common.Hash
 	var transaction *types.Transaction
+	var receipt *types.Receipt
+	var txErr error
+	var sent []*types.Transaction
 
 	// Keep retrying 'f' until the post-condition check passes or the context
 	// times out.
 	var postConPassed = false
-	for !postConPassed {
+	for attempt := 1; !postConPassed; attempt++ {
 		// If context is done, return error
 		select {
 		case <-ctx.Done():
@@ -213,55 +322,132 @@ func (account *account) Transact(ctx context.Context, speed TxExecutionSpeed, pr
 		default:
 		}
 
-		if err := func() error {
-			account.mu.Lock()
-			defer account.mu.Unlock()
+		receipt = nil
+		if txErr = func() error {
+			// Retrieve the gas price before locking the account, so that a
+			// slow response does not hold up other transactions
+			gasPrice, _ := SuggestedGasPrice(options.Speed)
 
-			account.updateGasPrice(speed)
-			// This will attempt to execute 'f' until no nonce error is
-			// returned or if ctx times out
-			innerCtx, innerCancel := context.WithTimeout(ctx, 10*time.Minute)
+			innerCtx, innerCancel := context.WithTimeout(ctx, options.TxTimeout)
 			defer innerCancel()
 
-			tx, err := account.retryNonceTx(innerCtx, f)
-			if err != nil {
-				return err
+			// The account is only locked while a nonce is allocated and the
+			// transaction is broadcast, so that many transactions from this
+			// account can be in flight at once
+			var tx *types.Transaction
+			if options.Idempotent {
+				tx = account.client.previousTx(innerCtx, sent)
+			}
+			if tx == nil {
+				var err error
+				tx, err = func() (*types.Transaction, error) {
+					account.mu.Lock()
+					defer account.mu.Unlock()
+
+					account.updateGasPrice(gasPrice)
+					// This will attempt to execute 'f' until no nonce error is
+					// returned or if ctx times out
+					return account.retryNonceTx(innerCtx, f, options)
+				}()
+				if err != nil {
+					return err
+				}
+				sent = append(sent, tx)
 			}
 
-			if _, err := account.client.WaitMined(innerCtx, tx); err != nil {
+			// Dropped transactions are sent again, and stuck transactions are
+			// replaced with a higher gas price, a few times before the
+			// attempt is given up
+			watchOptions := options.Watch
+			watchOptions.Resign = func(tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+				if options.MaxGasPrice != nil && gasPrice.Cmp(options.MaxGasPrice) > 0 {
+					return nil, fmt.Errorf("replacement gas price %v exceeds the maximum gas price %v", gasPrice, options.MaxGasPrice)
+				}
+				return account.resignTx(ctx, tx, gasPrice)
+			}
+			watchOptions.Superseded = account.supersededTx
+			result, err := account.client.WatchTx(innerCtx, tx, watchOptions)
+			if result.Tx != nil && result.Tx != tx {
+				account.replaceTx(result.Tx)
+				tx = result.Tx
+				sent = append(sent, tx)
+			}
+			if err == nil {
+				err = result.Err()
+			}
+			if err != nil {
+				// If the transaction has been dropped, the transactions behind
+				// it are re-sequenced so that they are not stuck
+				account.settleTx(tx, false)
 				return err
 			}
+			receipt = result.Receipt
+			account.settleTx(tx, true)
 			txHash = tx.Hash()
 			transaction = tx
 
 			// Transaction did not error, proceed to post-condition checks
 			return nil
-		}(); err != nil {
+		}(); txErr != nil {
+			// The transaction violates the spending policy of the signer and
+			// will never be signed
+			if _, ok := txErr.(*SpendingPolicyError); ok {
+				return nil, txErr
+			}
 			// There is another transaction with the same nonce and a higher or
 			// equal gas price as that of this transaction.
-			if strings.Compare(err.Error(), core.ErrReplaceUnderpriced.Error()) == 0 {
+			if strings.Compare(txErr.Error(), core.ErrReplaceUnderpriced.Error()) == 0 {
 				return nil, ErrNonceIsOutOfSync
 			}
-			fmt.Println(err)
+			fmt.Println(txErr)
 		}
 
-		for i := 0; i < 180; i++ {
-			select {
-			case <-ctx.Done():
+		// Post-conditions without a check function are re-evaluated when
+		// something they depend on happens, instead of being polled
+		if options.PostConditionCheck == nil && options.PostCondition != nil {
+			err := account.client.WaitForCondition(ctx, options.PostCondition, receipt, options.PostConditionTimeout)
+			if ctx.Err() != nil {
 				return nil, ErrPostConditionCheckFailed
-			default:
-				if postConditionCheck == nil || postConditionCheck() {
-					postConPassed = true
-				}
 			}
-			if postConPassed {
-				break
+			postConPassed = err == nil
+		} else {
+			postConDeadline := time.Now().Add(options.PostConditionTimeout)
+			for time.Now().Before(postConDeadline) {
+				select {
+				case <-ctx.Done():
+					return nil, ErrPostConditionCheckFailed
+				default:
+					if options.PostConditionCheck == nil || options.PostConditionCheck() {
+						postConPassed = true
+					}
+					// The post-condition is evaluated at the block of the last
+					// transaction that has been mined
+					if postConPassed && options.PostCondition != nil {
+						ok, err := CheckCondition(ctx, account.client, options.PostCondition, receipt)
+						postConPassed = err == nil && ok
+					}
+				}
+				if postConPassed {
+					break
+				}
+				time.Sleep(time.Second)
 			}
-			time.Sleep(time.Second)
 		}
 
 		// If post-condition check passes, proceed to wait for a specified
 		// number of blocks to be confirmed after the transaction's block
+		if postConPassed {
+			break
+		}
+
+		// Stop once the retry policy has been exhausted, returning the error
+		// of the last attempt if it did not reach the post-condition check
+		if options.Retry.MaxAttempts > 0 && attempt >= options.Retry.MaxAttempts {
+			if txErr != nil {
+				return nil, txErr
+			}
+			return nil, ErrPostConditionCheckFailed
+		}
 
 		// Wait for sometime before attempting to execute the transaction
 		// again. If context is done, return error to indicate that
@@ -269,13 +455,13 @@ func (account *account) Transact(ctx context.Context, speed TxExecutionSpeed, pr
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case <-time.After(sleepDurationMs * time.Millisecond):
+		case <-time.After(sleepDuration):
 		}
 
-		// Increase delay for next round but saturate at 30s
-		sleepDurationMs = time.Duration(float64(sleepDurationMs) * 1.6)
-		if sleepDurationMs > 30000 {
-			sleepDurationMs = 30000
+		// Increase delay for next round but saturate at the maximum delay
+		sleepDuration = time.Duration(float64(sleepDuration) * options.Retry.Multiplier)
+		if sleepDuration > options.Retry.MaxDelay {
+			sleepDuration = options.Retry.MaxDelay
 		}
 	}
 
@@ -284,63 +470,69 @@ func (account *account) Transact(ctx context.Context, speed TxExecutionSpeed, pr
 	// wait for a pre-defined number of blocks to be confirmed on the
 	// blockchain after the transaction's block is confirmed
 
-	// Attempt to get block number of transaction. If context times out, an
-	// error will be returned.
-	blockNumber, err := account.client.TxBlockNumber(ctx, txHash.String())
-	if err != nil {
-		return nil, err
-	}
-
-	// Attempt to get current block number. If context times out, an error will
-	// be returned.
-	currentBlockNumber, err := account.client.CurrentBlockNumber(ctx)
-	if err != nil {
+	if err := account.client.WaitConfirmations(ctx, txHash, options.ConfirmBlocks); err != nil {
 		return nil, err
 	}
-
-	// Keep getting current block number until it is greater than
-	// 'waitForBlocks' + transaction's block number. If context times out, the
-	// error is returned.
-	for big.NewInt(0).Sub(currentBlockNumber, blockNumber).Cmp(big.NewInt(waitForBlocks)) < 0 {
-		currentBlockNumber, err = account.client.CurrentBlockNumber(ctx)
-		if err != nil {
-			select {
-			case <-ctx.Done():
-				return nil, ctx.Err()
-			case <-time.After(time.Second):
-			}
-			continue
-		}
-	}
 	return transaction, nil
 }
 
 // Transfer transfers eth from the account to an ethereum address. If the value
 // is nil then it transfers all the balance to the `to` address.
 func (account *account) Transfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, confirmBlocks int64, sendAll bool) (*types.Transaction, error) {
+	return account.TransferWithOptions(ctx, to, value, sendAll, DefaultTransactOptions(speed, confirmBlocks))
+}
+
+// TransferWithOptions transfers eth from the account to an ethereum address,
+// as configured by the options. The value of the options is ignored in favour
+// of the transferred value.
+func (account *account) TransferWithOptions(ctx context.Context, to common.Address, value *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error) {
 	// Pre-condition check: Check if the account has enough balance
-	preConditionCheck := func() bool {
-		accountBalance, err := account.client.BalanceOf(ctx, account.Address())
+	options.PreConditionCheck = transferPreConditionCheck(ctx, account, value, sendAll, options.PreConditionCheck)
+
+	// Transaction: Transfer eth to address
+	f := account.transferTx(ctx, to, value, sendAll)
+	return account.TransactWithOptions(ctx, f, options)
+}
+
+// transferPreConditionCheck returns a pre-condition check that passes if the
+// account has enough balance for the transfer, and the given pre-condition
+// check (if not nil) passes as well.
+func transferPreConditionCheck(ctx context.Context, account Account, value *big.Int, sendAll bool, preConditionCheck func() bool) func() bool {
+	return func() bool {
+		if preConditionCheck != nil && !preConditionCheck() {
+			return false
+		}
+		accountBalance, err := account.BalanceAt(ctx, nil)
 		return sendAll || err == nil && accountBalance.Cmp(value) >= 0
 	}
+}
 
-	// Transaction: Transfer eth to address
-	f := func(transactOpts *bind.TransactOpts) (*types.Transaction, error) {
+// transferTx returns a transaction function that transfers eth from the
+// account to an ethereum address. If sendAll is set, the value is replaced by
+// the balance of the account minus the cost of gas.
+func (account *account) transferTx(ctx context.Context, to common.Address, value *big.Int, sendAll bool) func(*bind.TransactOpts) (*types.Transaction, error) {
+	return func(transactOpts *bind.TransactOpts) (*types.Transaction, error) {
 		bound := bind.NewBoundContract(to, abi.ABI{}, nil, account.client.EthClient(), nil)
 
+		// Transfers to contracts might need more gas than a plain transfer
+		gasLimit := uint64(21000)
+		if transactOpts.GasLimit != 0 {
+			gasLimit = transactOpts.GasLimit
+		}
+
 		if sendAll {
 			balance, err := account.BalanceAt(ctx, nil)
 			if err != nil {
 				return nil, err
 			}
-			value = new(big.Int).Sub(balance, new(big.Int).Mul(big.NewInt(21000), transactOpts.GasPrice))
+			value = new(big.Int).Sub(balance, new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), transactOpts.GasPrice))
 		}
 
 		transactor := &bind.TransactOpts{
 			From:     transactOpts.From,
 			Signer:   transactOpts.Signer,
 			Value:    value,
-			GasLimit: 21000,
+			GasLimit: gasLimit,
 			Context:  ctx,
 		}
 		if transactOpts.Nonce != nil {
@@ -356,7 +548,6 @@ func (account *account) Transfer(ctx context.Context, to common.Address, value *
 		}
 		return tx, nil
 	}
-	return account.Transact(ctx, speed, preConditionCheck, f, nil, confirmBlocks)
 }
 
 func (account *account) ContractTransactCtor(ctx context.Context, contractAddress common.Address, fnName string, params ...[]byte) (func(transactOpts *bind.TransactOpts) (*types.Transaction, error), error) {
@@ -444,9 +635,27 @@ func (account *account) ContractTransactCtor(ctx context.Context, contractAddres
 	}, nil
 }
 
-// Sign the given message with the account's private key.
+// Sign the given message with the account's private key. Accounts with a
+// spending policy only sign hashes if the policy allows raw signing.
 func (account *account) Sign(msgHash []byte) ([]byte, error) {
-	return crypto.Sign(msgHash, account.privateKey)
+	return account.signer.SignHash(context.Background(), msgHash)
+}
+
+// SignPersonalMessage signs the EIP-191 hash of the message with the account's
+// private key. The signature has a V of 27 or 28.
+func (account *account) SignPersonalMessage(msg []byte) ([]byte, error) {
+	return SignPersonalMessage(context.Background(), account.signer, msg)
+}
+
+// SignTypedData signs the EIP-712 digest of the typed data with the account's
+// private key. The signature has a V of 27 or 28.
+func (account *account) SignTypedData(typedData TypedData) ([]byte, error) {
+	return SignTypedData(context.Background(), account.signer, typedData)
+}
+
+// Signer returns the signer that holds the account's key.
+func (account *account) Signer() Signer {
+	return account.signer
 }
 
 // SetGasPrice will allow the caller to set gas price of transactOpts.
@@ -475,7 +684,8 @@ func (account *account) ResetToPendingNonce(ctx context.Context, coolDown time.D
 	if err != nil {
 		return err
 	}
-	account.transactOpts.Nonce = big.NewInt(int64(nonce))
+	account.resetNonce(nonce)
+	account.prepared = map[uint64]bool{}
 	return nil
 }
 
@@ -518,8 +728,9 @@ func SuggestedGasPrice(txSpeed TxExecutionSpeed) (*big.Int, error) {
 }
 
 // retryNonceTx retries transaction execution on the blockchain until nonce
-// errors are not seen, or until the context times out.
-func (account *account) retryNonceTx(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+// errors are not seen, or until the context times out. This function expects
+// the caller to hold the account's mutex.
+func (account *account) retryNonceTx(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error) {
 
 	select {
 	case <-ctx.Done():
@@ -527,44 +738,66 @@ func (account *account) retryNonceTx(ctx context.Context, f func(*bind.TransactO
 	default:
 	}
 
-	transactor := &bind.TransactOpts{
-		From:     account.transactOpts.From,
-		Signer:   account.transactOpts.Signer,
-		Value:    big.NewInt(0),
-		GasLimit: account.transactOpts.GasLimit,
-		Context:  ctx,
-	}
-	if account.transactOpts.Nonce != nil {
-		transactor.Nonce = big.NewInt(0).Set(account.transactOpts.Nonce)
-	}
-	if account.transactOpts.GasPrice != nil {
-		transactor.GasPrice = big.NewInt(0).Set(account.transactOpts.GasPrice)
+	// A nonce chosen by the caller is used as it is, and the account's nonce
+	// is moved past it
+	if options.Nonce != nil {
+		transactor := options.transactor(account.transactOpts, new(big.Int).Set(options.Nonce))
+		transactor.Context = ctx
+		transactor.Signer = account.signerFn(ctx)
+		tx, err := f(transactor)
+		if err != nil {
+			return tx, err
+		}
+		if options.Nonce.Cmp(account.transactOpts.Nonce) >= 0 {
+			account.resetNonce(options.Nonce.Uint64() + 1)
+		}
+		account.trackTx(tx)
+		return tx, nil
 	}
 
+	nonce := account.nextNonce()
+	transactor := options.transactor(account.transactOpts, nonce)
+	transactor.Context = ctx
+	transactor.Signer = account.signerFn(ctx)
+
 	tx, err := f(transactor)
 
-	// On successful execution, increment nonce in transactOpts and return
+	// On successful execution, mark the nonce as used and return
 	if err == nil {
-		account.transactOpts.Nonce.Add(account.transactOpts.Nonce, big.NewInt(1))
+		account.useNonce()
+		account.trackTx(tx)
 		return tx, nil
 	}
 
 	// Process errors to check for nonce issues
 	// If error indicates that nonce is too low, increment nonce and retry
 	if err == core.ErrNonceTooLow || strings.Contains(err.Error(), "nonce is too low") {
-		account.transactOpts.Nonce.Add(account.transactOpts.Nonce, big.NewInt(1))
-		return account.retryNonceTx(ctx, f)
+		account.useNonce()
+		return account.retryNonceTx(ctx, f, options)
 	}
 
-	// If error indicates that nonce is too low, decrement nonce and retry
+	// If error indicates that nonce is too high, resync the nonce with the
+	// pending nonce and retry. The nonces of journaled transactions are
+	// skipped, since other operations might still be waiting for them, and
+	// the error is returned if the nonce would not move back.
 	if err == core.ErrNonceTooHigh || strings.Contains(err.Error(), "nonce is too high") {
-		account.transactOpts.Nonce.Sub(account.transactOpts.Nonce, big.NewInt(1))
-		return account.retryNonceTx(ctx, f)
+		pendingNonce, pendingErr := account.client.EthClient().PendingNonceAt(ctx, account.transactOpts.From)
+		if pendingErr != nil {
+			return tx, err
+		}
+		for _, ok := account.journal[pendingNonce]; ok; _, ok = account.journal[pendingNonce] {
+			pendingNonce++
+		}
+		if pendingNonce >= nonce.Uint64() {
+			return tx, err
+		}
+		account.resetNonce(pendingNonce)
+		return account.retryNonceTx(ctx, f, options)
 	}
 
 	// If any other type of nonce error occurs we will refresh the nonce and
 	// try again for up to 1 minute
-	var nonce uint64
+	var pendingNonce uint64
 	for try := 0; try < 60 && strings.Contains(err.Error(), "nonce"); try++ {
 		select {
 		case <-ctx.Done():
@@ -573,14 +806,16 @@ func (account *account) retryNonceTx(ctx context.Context, f func(*bind.TransactO
 		}
 
 		// Get updated nonce and retry 'f'
-		nonce, err = account.client.EthClient().PendingNonceAt(ctx, account.transactOpts.From)
+		pendingNonce, err = account.client.EthClient().PendingNonceAt(ctx, account.transactOpts.From)
 		if err != nil {
 			continue
 		}
-		account.transactOpts.Nonce = big.NewInt(int64(nonce))
+		account.resetNonce(pendingNonce)
+		transactor.Nonce = account.nextNonce()
 
-		if tx, err = f(account.transactOpts); err == nil {
-			account.transactOpts.Nonce.Add(account.transactOpts.Nonce, big.NewInt(1))
+		if tx, err = f(transactor); err == nil {
+			account.useNonce()
+			account.trackTx(tx)
 			return tx, nil
 		}
 	}
@@ -588,15 +823,11 @@ func (account *account) retryNonceTx(ctx context.Context, f func(*bind.TransactO
 	return tx, err
 }
 
-// updateGasPrice will retrieve the current 'fast' gas price
-// and update the account's transactOpts. This function expects the caller to
-// handle potential data race conditions (i.e. Locking of mutex prior to
-// calling this method)
-func (account *account) updateGasPrice(txSpeed TxExecutionSpeed) {
-	gasPrice, err := SuggestedGasPrice(txSpeed)
-	if err != nil {
-		return
-	}
+// updateGasPrice will update the account's transactOpts with the given gas
+// price, unless it is nil. This function expects the caller to handle
+// potential data race conditions (i.e. Locking of mutex prior to calling this
+// method)
+func (account *account) updateGasPrice(gasPrice *big.Int) {
 	if gasPrice != nil {
 		account.transactOpts.GasPrice = gasPrice
 	}
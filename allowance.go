@@ -0,0 +1,136 @@
+package libeth
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// AllowanceOptions configure EnsureAllowance. The transact options apply to
+// every transaction that is sent.
+type AllowanceOptions struct {
+	TransactOptions
+
+	// Unlimited approves the maximum allowance instead of the minimum, so
+	// that the allowance does not have to be raised again.
+	Unlimited bool
+
+	// Exact lowers an allowance that is above the minimum down to it. It is
+	// ignored when Unlimited is set.
+	Exact bool
+
+	// ResetToZero always resets a non-zero allowance to zero before approving
+	// the new allowance. Otherwise, it is only reset if the token rejects the
+	// approval, which is how tokens with approve-race protection behave.
+	ResetToZero bool
+}
+
+// EnsureAllowance makes sure that the allowance of the spender from the
+// account is at least the minimum, and returns the last transaction that it
+// sent. No transaction is sent, and nil is returned, if the allowance is
+// already enough. Otherwise, the allowance is changed with increaseAllowance
+// or decreaseAllowance if the token supports them, or with approve.
+func (erc20 *erc20) EnsureAllowance(ctx context.Context, spender common.Address, min *big.Int, options AllowanceOptions) (*types.Transaction, error) {
+	owner := erc20.account.Address()
+	current, err := erc20.Allowance(ctx, owner, spender)
+	if err != nil {
+		return nil, err
+	}
+
+	target := new(big.Int).Set(min)
+	if options.Unlimited {
+		target = new(big.Int).Set(math.MaxBig256)
+		options.Exact = false
+	}
+	if current.Cmp(min) == 0 || current.Cmp(min) > 0 && !options.Exact {
+		return nil, nil
+	}
+
+	// The spender can use the allowance as soon as it is granted, so raising
+	// it only has to reach the minimum, and lowering it only has to get below
+	// the target
+	transactOptions := options.TransactOptions
+	if current.Cmp(min) < 0 {
+		transactOptions.PostCondition = erc20.allowanceCondition(owner, spender, min, 1, options.PostCondition)
+	} else {
+		transactOptions.PostCondition = erc20.allowanceCondition(owner, spender, target, -1, options.PostCondition)
+	}
+
+	// Change a non-zero allowance relatively, which is not affected by the
+	// approve race. The delta is computed once, so a retry must never apply
+	// it a second time.
+	if current.Sign() > 0 && !options.Unlimited {
+		transactOptions.Idempotent = true
+		method, delta := "increaseAllowance", new(big.Int).Sub(target, current)
+		if delta.Sign() < 0 {
+			method, delta = "decreaseAllowance", delta.Neg(delta)
+		}
+		if erc20.accepts(ctx, method, spender, delta) {
+			transactor, err := bindings.NewERC20Transactor(erc20.address, bind.ContractTransactor(erc20.client.EthClient()))
+			if err != nil {
+				return nil, err
+			}
+			return erc20.account.TransactWithOptions(ctx, func(tops *bind.TransactOpts) (*types.Transaction, error) {
+				if method == "increaseAllowance" {
+					return transactor.IncreaseAllowance(tops, spender, delta)
+				}
+				return transactor.DecreaseAllowance(tops, spender, delta)
+			}, transactOptions)
+		}
+	}
+
+	if current.Sign() > 0 && (options.ResetToZero || !erc20.accepts(ctx, "approve", spender, target)) {
+		// The post-condition of the options is about the new allowance, so
+		// it is only checked by the approval that follows the reset
+		resetOptions := options.TransactOptions
+		resetOptions.PostCondition = erc20.allowanceCondition(owner, spender, big.NewInt(0), -1, nil)
+		resetOptions.PostConditionCheck = nil
+		if _, err := erc20.ApproveWithOptions(ctx, spender, big.NewInt(0), resetOptions); err != nil {
+			return nil, err
+		}
+		// The token rejected the approval before the reset, so it cannot be
+		// simulated against the latest block
+		return erc20.approve(ctx, spender, target, transactOptions, false)
+	}
+	return erc20.ApproveWithOptions(ctx, spender, target, transactOptions)
+}
+
+// accepts returns true if calling the method of the token from the account
+// with the spender and value returns true. Tokens that do not implement the
+// method, or that would revert, do not accept it.
+func (erc20 *erc20) accepts(ctx context.Context, method string, spender common.Address, value *big.Int) bool {
+	data, err := erc20ABI.Pack(method, spender, value)
+	if err != nil {
+		return false
+	}
+	resp, err := erc20.client.EthClient().CallContract(ctx, ethereum.CallMsg{
+		From: erc20.account.Address(),
+		To:   &erc20.address,
+		Data: data,
+	}, nil)
+	return err == nil && len(resp) == 32 && new(big.Int).SetBytes(resp).Sign() != 0
+}
+
+// allowanceCondition returns a condition that passes if the allowance of the
+// spender from the owner is at least (for a sign of 1), at most (for a sign of
+// -1) or exactly (for a sign of 0) the value, and if the other condition
+// passes.
+func (erc20 *erc20) allowanceCondition(owner, spender common.Address, value *big.Int, sign int, other Condition) Condition {
+	condition := DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		allowance, err := erc20.bindings.Allowance(&bind.CallOpts{BlockNumber: block, Context: ctx}, owner, spender)
+		if err != nil {
+			return false, err
+		}
+		return allowance.Cmp(value) == 0 || allowance.Cmp(value) == sign, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{erc20.address}}}})
+	if other != nil {
+		return All(other, condition)
+	}
+	return condition
+}
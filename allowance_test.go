@@ -0,0 +1,148 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("allowances", func() {
+
+	token := common.HexToAddress("0xbeef")
+	spender := common.HexToAddress("0x5e4d")
+
+	selector := func(signature string) []byte {
+		return crypto.Keccak256([]byte(signature))[:4]
+	}
+	word := func(value *big.Int) []byte {
+		return common.LeftPadBytes(value.Bytes(), 32)
+	}
+
+	// ensure calls EnsureAllowance from a dry-run account against a token with
+	// the given allowance, and returns the selectors of the recorded
+	// transactions.
+	ensure := func(setup func(eth *FakeEth), min *big.Int, options libeth.AllowanceOptions) [][]byte {
+		server, eth := newFakeNode()
+		defer server.Close()
+		client, err := libeth.Connect(libeth.Localnet, server.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		key, err := crypto.GenerateKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		eth.respond(token, selector("approve(address,uint256)"), word(big.NewInt(1)))
+		setup(eth)
+
+		erc20, err := account.NewERC20(token.Hex())
+		Expect(err).ShouldNot(HaveOccurred())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = erc20.EnsureAllowance(ctx, spender, min, options)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		selectors := [][]byte{}
+		for _, tx := range account.Log() {
+			selectors = append(selectors, tx.Tx.Data()[:4])
+		}
+		return selectors
+	}
+
+	Context("when the allowance is enough", func() {
+		It("should not send a transaction", func() {
+			selectors := ensure(func(eth *FakeEth) {
+				eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(100)))
+			}, big.NewInt(50), libeth.AllowanceOptions{})
+			Expect(selectors).Should(BeEmpty())
+		})
+	})
+
+	Context("when the allowance is too low", func() {
+		It("should approve from zero", func() {
+			selectors := ensure(func(eth *FakeEth) {
+				eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(0)))
+			}, big.NewInt(50), libeth.AllowanceOptions{})
+			Expect(selectors).Should(Equal([][]byte{selector("approve(address,uint256)")}))
+		})
+
+		It("should increase the allowance if the token supports it", func() {
+			selectors := ensure(func(eth *FakeEth) {
+				eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(10)))
+				eth.respond(token, selector("increaseAllowance(address,uint256)"), word(big.NewInt(1)))
+			}, big.NewInt(50), libeth.AllowanceOptions{})
+			Expect(selectors).Should(Equal([][]byte{selector("increaseAllowance(address,uint256)")}))
+		})
+
+		It("should reset to zero first if the token rejects the approval", func() {
+			selectors := ensure(func(eth *FakeEth) {
+				eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(10)))
+				approve := append(selector("approve(address,uint256)"), common.LeftPadBytes(spender.Bytes(), 32)...)
+				eth.respondTo(token, append(approve, word(big.NewInt(50))...), word(big.NewInt(0)))
+			}, big.NewInt(50), libeth.AllowanceOptions{})
+			Expect(selectors).Should(Equal([][]byte{selector("approve(address,uint256)"), selector("approve(address,uint256)")}))
+		})
+
+		It("should only check the post-condition of the options after the reset", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The reset is mined in block 1 and the approval in block 2
+			allowance := append(selector("allowance(address,address)"), common.LeftPadBytes(account.Address().Bytes(), 32)...)
+			allowance = append(allowance, common.LeftPadBytes(spender.Bytes(), 32)...)
+			eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(10)))
+			eth.respondAt(token, allowance, 1, word(big.NewInt(0)))
+			eth.respondAt(token, allowance, 2, word(big.NewInt(50)))
+			eth.respond(token, selector("approve(address,uint256)"), word(big.NewInt(1)))
+			approve := append(selector("approve(address,uint256)"), common.LeftPadBytes(spender.Bytes(), 32)...)
+			eth.respondTo(token, append(approve, word(big.NewInt(50))...), word(big.NewInt(0)))
+			eth.mineWithLogs(ctx, 0)
+			eth.mineWithLogs(ctx, 1)
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			options := libeth.AllowanceOptions{TransactOptions: libeth.DefaultTransactOptions(libeth.Fast, 0)}
+			options.PostCondition = libeth.AllowanceAtLeast(token, account.Address(), spender, big.NewInt(50))
+			_, err = erc20.EnsureAllowance(ctx, spender, big.NewInt(50), options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(eth.minedTxs()).Should(HaveLen(2))
+		})
+
+		It("should approve the maximum allowance in unlimited mode", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.respond(token, selector("allowance(address,address)"), word(big.NewInt(0)))
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, err = erc20.EnsureAllowance(ctx, spender, big.NewInt(50), libeth.AllowanceOptions{Unlimited: true})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(account.Log()).Should(HaveLen(1))
+			Expect(account.Log()[0].Tx.Data()[36:]).Should(Equal(word(math.MaxBig256)))
+		})
+	})
+})
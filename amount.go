@@ -0,0 +1,238 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ErrInvalidAmount indicates that a string is not a decimal amount.
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// ErrPrecisionLoss indicates that an amount has more decimals than it can be
+// represented with.
+var ErrPrecisionLoss = errors.New("amount cannot be represented without losing precision")
+
+// Decimals of the units of Eth.
+const (
+	WeiDecimals   = 0
+	GweiDecimals  = 9
+	EtherDecimals = 18
+)
+
+// Amount is an exact decimal amount of Eth or of a token. It is stored as an
+// integer value in the smallest unit, together with the number of decimals of
+// that unit, so an amount of 1.5 Eth has a value of 1500000000000000000 and 18
+// decimals.
+type Amount struct {
+	value    *big.Int
+	decimals int
+}
+
+// NewAmount returns the amount with the given value in the smallest unit.
+func NewAmount(value *big.Int, decimals int) Amount {
+	return Amount{
+		value:    new(big.Int).Set(value),
+		decimals: decimals,
+	}
+}
+
+// WeiAmount returns the Eth amount of the given wei.
+func WeiAmount(wei *big.Int) Amount {
+	return NewAmount(wei, EtherDecimals)
+}
+
+// ParseAmount parses a human decimal string (e.g. "1.5") into an amount with
+// the given decimals. Strings with more fractional digits than decimals are
+// rejected with ErrPrecisionLoss.
+func ParseAmount(s string, decimals int) (Amount, error) {
+	s = strings.TrimSpace(s)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 2 || parts[0] == "" && (len(parts) == 1 || parts[1] == "") {
+		return Amount{}, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+	}
+	integer, fraction := parts[0], ""
+	if len(parts) == 2 {
+		fraction = strings.TrimRight(parts[1], "0")
+	}
+	if len(fraction) > decimals {
+		return Amount{}, ErrPrecisionLoss
+	}
+
+	digits := integer + fraction + strings.Repeat("0", decimals-len(fraction))
+	if strings.TrimLeft(digits, "0123456789") != "" {
+		return Amount{}, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+	}
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Amount{}, fmt.Errorf("%v: %q", ErrInvalidAmount, s)
+	}
+	if negative {
+		value.Neg(value)
+	}
+	return Amount{value: value, decimals: decimals}, nil
+}
+
+// ParseEther parses a decimal string of ether into an Eth amount.
+func ParseEther(s string) (Amount, error) {
+	return ParseAmount(s, EtherDecimals)
+}
+
+// ParseGwei parses a decimal string of gwei into an Eth amount.
+func ParseGwei(s string) (Amount, error) {
+	// The value in wei of an amount of gwei is the value of the amount with 9
+	// decimals
+	amount, err := ParseAmount(s, GweiDecimals)
+	if err != nil {
+		return Amount{}, err
+	}
+	return WeiAmount(amount.value), nil
+}
+
+// Value returns the value in the smallest unit.
+func (amount Amount) Value() *big.Int {
+	if amount.value == nil {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(amount.value)
+}
+
+// Decimals returns the number of decimals of the smallest unit.
+func (amount Amount) Decimals() int {
+	return amount.decimals
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of the amount.
+func (amount Amount) Sign() int {
+	return amount.Value().Sign()
+}
+
+// String formats the amount as a human decimal string, without trailing
+// zeros.
+func (amount Amount) String() string {
+	return amount.FormatUnits(amount.decimals)
+}
+
+// FormatUnits formats the amount as a decimal string of a unit worth 10^decimals
+// of the smallest unit. For Eth amounts, FormatUnits(GweiDecimals) returns the amount in
+// gwei and FormatUnits(WeiDecimals) in wei.
+func (amount Amount) FormatUnits(decimals int) string {
+	value := amount.Value()
+	shift := decimals
+	if shift < 0 {
+		value.Mul(value, pow10(-shift))
+		shift = 0
+	}
+
+	sign := ""
+	if value.Sign() < 0 {
+		sign = "-"
+		value.Neg(value)
+	}
+	digits := value.String()
+	if len(digits) <= shift {
+		digits = strings.Repeat("0", shift-len(digits)+1) + digits
+	}
+	integer, fraction := digits[:len(digits)-shift], strings.TrimRight(digits[len(digits)-shift:], "0")
+	if fraction == "" {
+		return sign + integer
+	}
+	return sign + integer + "." + fraction
+}
+
+// Rescale returns the same amount with the given decimals. It returns
+// ErrPrecisionLoss if the amount cannot be represented exactly.
+func (amount Amount) Rescale(decimals int) (Amount, error) {
+	value := amount.Value()
+	if decimals >= amount.decimals {
+		return Amount{value: value.Mul(value, pow10(decimals-amount.decimals)), decimals: decimals}, nil
+	}
+	quotient, remainder := new(big.Int).QuoRem(value, pow10(amount.decimals-decimals), new(big.Int))
+	if remainder.Sign() != 0 {
+		return Amount{}, ErrPrecisionLoss
+	}
+	return Amount{value: quotient, decimals: decimals}, nil
+}
+
+// Add returns the sum of the amounts, with the larger number of decimals.
+func (amount Amount) Add(other Amount) Amount {
+	a, b := align(amount, other)
+	return Amount{value: a.value.Add(a.value, b.value), decimals: a.decimals}
+}
+
+// Sub returns the difference of the amounts, with the larger number of
+// decimals.
+func (amount Amount) Sub(other Amount) Amount {
+	a, b := align(amount, other)
+	return Amount{value: a.value.Sub(a.value, b.value), decimals: a.decimals}
+}
+
+// Mul returns the amount multiplied by an integer.
+func (amount Amount) Mul(factor *big.Int) Amount {
+	value := amount.Value()
+	return Amount{value: value.Mul(value, factor), decimals: amount.decimals}
+}
+
+// Cmp compares the amounts, and returns -1, 0 or 1 like big.Int.Cmp.
+func (amount Amount) Cmp(other Amount) int {
+	a, b := align(amount, other)
+	return a.value.Cmp(b.value)
+}
+
+// align returns copies of the amounts with the same number of decimals.
+func align(a, b Amount) (Amount, Amount) {
+	decimals := a.decimals
+	if b.decimals > decimals {
+		decimals = b.decimals
+	}
+	// Rescaling to more decimals is always exact
+	a, _ = a.Rescale(decimals)
+	b, _ = b.Rescale(decimals)
+	return a, b
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Amount returns the amount of the token with the given value in its smallest
+// unit.
+func (info TokenInfo) Amount(value *big.Int) Amount {
+	return NewAmount(value, int(info.Decimals))
+}
+
+// ParseAmount parses a human decimal string into an amount of the token.
+func (info TokenInfo) ParseAmount(s string) (Amount, error) {
+	return ParseAmount(s, int(info.Decimals))
+}
+
+// tokenAmount returns the amount of the token with the given value in its
+// smallest unit.
+func tokenAmount(ctx context.Context, token ERC20View, value *big.Int) (Amount, error) {
+	info, err := token.Info(ctx)
+	if err != nil {
+		return Amount{}, err
+	}
+	return info.Amount(value), nil
+}
+
+// tokenValue returns the value of the amount in the smallest unit of the
+// token. Amounts with more decimals than the token are rejected with
+// ErrPrecisionLoss rather than rounded.
+func tokenValue(ctx context.Context, token ERC20View, amount Amount) (*big.Int, error) {
+	info, err := token.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	amount, err = amount.Rescale(int(info.Decimals))
+	if err != nil {
+		return nil, err
+	}
+	return amount.Value(), nil
+}
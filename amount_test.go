@@ -0,0 +1,107 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("amounts", func() {
+
+	Context("when parsing and formatting amounts", func() {
+		It("should round-trip decimal strings exactly", func() {
+			for _, s := range []string{"0", "1", "1.5", "-0.000000000000000001", "123456789.123456789"} {
+				amount, err := libeth.ParseAmount(s, 18)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(amount.String()).Should(Equal(s))
+			}
+
+			amount, err := libeth.ParseAmount("0.12345678", 8)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(amount.Value()).Should(Equal(big.NewInt(12345678)))
+		})
+
+		It("should reject invalid strings and excess precision", func() {
+			for _, s := range []string{"", ".", "1.2.3", "1e18", "0x10", "1,5"} {
+				_, err := libeth.ParseAmount(s, 18)
+				Expect(err).Should(HaveOccurred())
+			}
+			_, err := libeth.ParseAmount("0.123456789", 8)
+			Expect(err).Should(Equal(libeth.ErrPrecisionLoss))
+			_, err = libeth.ParseAmount("0.123456780", 8)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when converting eth units", func() {
+		It("should convert between wei, gwei and ether", func() {
+			amount, err := libeth.ParseGwei("20.5")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(amount.Value()).Should(Equal(big.NewInt(20500000000)))
+			Expect(amount.FormatUnits(libeth.WeiDecimals)).Should(Equal("20500000000"))
+			Expect(amount.FormatUnits(libeth.GweiDecimals)).Should(Equal("20.5"))
+			Expect(amount.FormatUnits(libeth.EtherDecimals)).Should(Equal("0.0000000205"))
+
+			_, err = libeth.ParseGwei("0.0000000001")
+			Expect(err).Should(Equal(libeth.ErrPrecisionLoss))
+		})
+	})
+
+	Context("when doing arithmetic", func() {
+		It("should be exact across decimals", func() {
+			a, err := libeth.ParseAmount("0.1", 8)
+			Expect(err).ShouldNot(HaveOccurred())
+			b, err := libeth.ParseAmount("0.2", 18)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			sum := a.Add(b)
+			Expect(sum.String()).Should(Equal("0.3"))
+			Expect(sum.Decimals()).Should(Equal(18))
+			Expect(a.Sub(b).String()).Should(Equal("-0.1"))
+			Expect(a.Mul(big.NewInt(3)).String()).Should(Equal("0.3"))
+			Expect(a.Cmp(b)).Should(Equal(-1))
+
+			_, err = sum.Rescale(0)
+			Expect(err).Should(Equal(libeth.ErrPrecisionLoss))
+			rescaled, err := sum.Rescale(8)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(rescaled.Value()).Should(Equal(big.NewInt(30000000)))
+		})
+	})
+
+	Context("when reading token amounts", func() {
+		It("should use the decimals of the token", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			selector := func(signature string) []byte {
+				return crypto.Keccak256([]byte(signature))[:4]
+			}
+			token := common.HexToAddress("0xbeef")
+			eth.respond(token, selector("name()"), abiString("Wrapped BTC"))
+			eth.respond(token, selector("symbol()"), abiString("WBTC"))
+			eth.respond(token, selector("decimals()"), common.LeftPadBytes([]byte{8}, 32))
+			eth.respond(token, selector("totalSupply()"), common.LeftPadBytes(big.NewInt(0).Bytes(), 32))
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes(big.NewInt(150000000).Bytes(), 32))
+
+			erc20, err := client.NewERC20View(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			balance, err := erc20.BalanceOfAmount(ctx, common.HexToAddress("0x1"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(balance.String()).Should(Equal("1.5"))
+			Expect(balance.Decimals()).Should(Equal(8))
+		})
+	})
+})
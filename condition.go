@@ -0,0 +1,342 @@
+package libeth
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// Condition is a check of the state of the Ethereum blockchain at a block.
+// The receipt is the receipt of the transaction that the condition is a
+// post-condition of, and nil for pre-conditions. Evaluating all parts of a
+// composite condition at the same block gives a consistent result.
+type Condition interface {
+	Check(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error)
+}
+
+// ConditionFunc is a Condition implemented by a function.
+type ConditionFunc func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error)
+
+// Check calls the function. A ConditionFunc depends on new heads.
+func (f ConditionFunc) Check(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+	return f(ctx, client, block, receipt)
+}
+
+// CheckCondition evaluates the condition at the block of the receipt, or at
+// the latest block if there is no receipt.
+func CheckCondition(ctx context.Context, client Client, condition Condition, receipt *types.Receipt) (bool, error) {
+	block, err := conditionBlock(ctx, client, receipt)
+	if err != nil {
+		return false, err
+	}
+	return condition.Check(ctx, client, block, receipt)
+}
+
+// conditionBlock returns the block of the receipt, or the latest block if
+// there is no receipt.
+func conditionBlock(ctx context.Context, client Client, receipt *types.Receipt) (*big.Int, error) {
+	if receipt != nil {
+		return client.TxBlockNumber(ctx, receipt.TxHash.Hex())
+	}
+	return client.CurrentBlockNumber(ctx)
+}
+
+// ConditionCheck returns a check for the preConditionCheck and
+// postConditionCheck arguments of Account.Transact, which evaluates the
+// condition at the latest block. Errors fail the check.
+func ConditionCheck(ctx context.Context, client Client, condition Condition) func() bool {
+	return func() bool {
+		ok, err := CheckCondition(ctx, client, condition, nil)
+		return err == nil && ok
+	}
+}
+
+// All returns a condition that passes if all of the conditions pass.
+func All(conditions ...Condition) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		for _, condition := range conditions {
+			if ok, err := condition.Check(ctx, client, block, receipt); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}), mergeDependencies(conditions))
+}
+
+// Any returns a condition that passes if any of the conditions pass. Errors
+// are only returned if no condition passes.
+func Any(conditions ...Condition) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		var lastErr error
+		for _, condition := range conditions {
+			ok, err := condition.Check(ctx, client, block, receipt)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if ok {
+				return true, nil
+			}
+		}
+		return false, lastErr
+	}), mergeDependencies(conditions))
+}
+
+// Not returns a condition that passes if the condition fails. Errors are not
+// negated.
+func Not(condition Condition) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		ok, err := condition.Check(ctx, client, block, receipt)
+		if err != nil {
+			return false, err
+		}
+		return !ok, nil
+	}), DependenciesOf(condition))
+}
+
+// BalanceAtLeast returns a condition that passes if the eth balance of the
+// address is at least the value plus the cost of the gas limit at the
+// suggested gas price.
+func BalanceAtLeast(address common.Address, value *big.Int, gasLimit uint64) Condition {
+	return ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		min := new(big.Int).Set(value)
+		if gasLimit > 0 {
+			gasPrice, err := client.EthClient().SuggestGasPrice(ctx)
+			if err != nil {
+				return false, err
+			}
+			min.Add(min, new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gasLimit)))
+		}
+		balance, err := client.EthClient().BalanceAt(ctx, address, block)
+		if err != nil {
+			return false, err
+		}
+		return balance.Cmp(min) >= 0, nil
+	})
+}
+
+// AllowanceAtLeast returns a condition that passes if the ERC20 allowance of
+// the spender from the owner is at least the value.
+func AllowanceAtLeast(token, owner, spender common.Address, value *big.Int) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		erc20, err := bindings.NewERC20Detailed(token, bind.ContractBackend(client.EthClient()))
+		if err != nil {
+			return false, err
+		}
+		allowance, err := erc20.Allowance(&bind.CallOpts{BlockNumber: block, Context: ctx}, owner, spender)
+		if err != nil {
+			return false, err
+		}
+		return allowance.Cmp(value) >= 0, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+}
+
+// TokenBalanceAtLeast returns a condition that passes if the ERC20 balance of
+// the address is at least the value.
+func TokenBalanceAtLeast(token, address common.Address, value *big.Int) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		erc20, err := bindings.NewERC20Detailed(token, bind.ContractBackend(client.EthClient()))
+		if err != nil {
+			return false, err
+		}
+		balance, err := erc20.BalanceOf(&bind.CallOpts{BlockNumber: block, Context: ctx}, address)
+		if err != nil {
+			return false, err
+		}
+		return balance.Cmp(value) >= 0, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+}
+
+// NFTOwnedBy returns a condition that passes if the ERC721 token is owned by
+// the address.
+func NFTOwnedBy(token common.Address, tokenID *big.Int, address common.Address) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		nft, err := bindings.NewERC721(token, bind.ContractBackend(client.EthClient()))
+		if err != nil {
+			return false, err
+		}
+		owner, err := nft.OwnerOf(&bind.CallOpts{BlockNumber: block, Context: ctx}, tokenID)
+		if err != nil {
+			return false, err
+		}
+		return owner == address, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+}
+
+// Token1155BalanceAtLeast returns a condition that passes if the ERC1155
+// balance of the address for the ID is at least the value.
+func Token1155BalanceAtLeast(token common.Address, id *big.Int, address common.Address, value *big.Int) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		multiToken, err := bindings.NewERC1155(token, bind.ContractBackend(client.EthClient()))
+		if err != nil {
+			return false, err
+		}
+		balance, err := multiToken.BalanceOf(&bind.CallOpts{BlockNumber: block, Context: ctx}, address, id)
+		if err != nil {
+			return false, err
+		}
+		return balance.Cmp(value) >= 0, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+}
+
+// EventEmitted returns a condition that passes if the receipt has a log of
+// the event, identified by its signature (e.g. "Transfer(address,address,uint256)"),
+// emitted by the contract. Topics after the event ID are matched in order,
+// and empty topics match anything. Without a receipt, the logs of the block
+// are searched instead.
+func EventEmitted(contract common.Address, signature string, topics ...common.Hash) Condition {
+	eventID := crypto.Keccak256Hash([]byte(signature))
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		logs := []*types.Log{}
+		if receipt != nil {
+			logs = receipt.Logs
+		} else {
+			blockLogs, err := client.EthClient().FilterLogs(ctx, ethereum.FilterQuery{FromBlock: block, ToBlock: block, Addresses: []common.Address{contract}})
+			if err != nil {
+				return false, err
+			}
+			for i := range blockLogs {
+				logs = append(logs, &blockLogs[i])
+			}
+		}
+		for _, log := range logs {
+			if log.Address == contract && matchTopics(log.Topics, append([]common.Hash{eventID}, topics...)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{contract}, Topics: [][]common.Hash{{eventID}}}}})
+}
+
+// receiptHasEvent returns a condition that passes if the receipt has a log of
+// the event emitted by the contract, like EventEmitted. Logs of other
+// transactions never match, so the condition fails without a receipt.
+func receiptHasEvent(contract common.Address, signature string, topics ...common.Hash) Condition {
+	eventID := crypto.Keccak256Hash([]byte(signature))
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		if receipt == nil {
+			return false, nil
+		}
+		for _, log := range receipt.Logs {
+			if log.Address == contract && matchTopics(log.Topics, append([]common.Hash{eventID}, topics...)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{contract}, Topics: [][]common.Hash{{eventID}}}}})
+}
+
+// receiptSucceeded returns a condition that passes if the receipt is of a
+// transaction that was mined without reverting.
+func receiptSucceeded() Condition {
+	return ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		return receipt != nil && receipt.Status == types.ReceiptStatusSuccessful, nil
+	})
+}
+
+// StorageEquals returns a condition that passes if the storage slot of the
+// contract holds the value.
+func StorageEquals(contract common.Address, slot, value common.Hash) Condition {
+	return ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		storage, err := client.EthClient().StorageAt(ctx, contract, slot, block)
+		if err != nil {
+			return false, err
+		}
+		return common.BytesToHash(storage) == value, nil
+	})
+}
+
+// CallReturns returns a condition that passes if calling the method of the
+// contract with the arguments returns the expected values.
+func CallReturns(contract common.Address, contractABI abi.ABI, method string, args []interface{}, want ...interface{}) Condition {
+	return ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		data, err := contractABI.Pack(method, args...)
+		if err != nil {
+			return false, err
+		}
+		wantData, err := contractABI.Methods[method].Outputs.Pack(want...)
+		if err != nil {
+			return false, err
+		}
+		resp, err := client.EthClient().CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, block)
+		if err != nil {
+			return false, err
+		}
+		return bytes.Equal(resp, wantData), nil
+	})
+}
+
+// matchTopics returns true if the topics start with the wanted topics. Empty
+// wanted topics match anything.
+func matchTopics(topics, want []common.Hash) bool {
+	if len(topics) < len(want) {
+		return false
+	}
+	for i := range want {
+		if want[i] != (common.Hash{}) && topics[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ConditionDependencies declare what a condition depends on, so that it is
+// only re-evaluated when something relevant happens. A condition that depends
+// on new heads is re-evaluated at every block, otherwise it is re-evaluated
+// at blocks with logs that match one of the queries. The block range of the
+// queries is ignored.
+type ConditionDependencies struct {
+	NewHeads bool
+	Logs     []ethereum.FilterQuery
+}
+
+// DependentCondition is a Condition that declares its dependencies.
+type DependentCondition interface {
+	Condition
+	Dependencies() ConditionDependencies
+}
+
+type dependentCondition struct {
+	Condition
+	dependencies ConditionDependencies
+}
+
+// DependsOn returns the condition with the given dependencies.
+func DependsOn(condition Condition, dependencies ConditionDependencies) DependentCondition {
+	return &dependentCondition{
+		Condition:    condition,
+		dependencies: dependencies,
+	}
+}
+
+func (condition *dependentCondition) Dependencies() ConditionDependencies {
+	return condition.dependencies
+}
+
+// DependenciesOf returns the dependencies of the condition. Conditions that do
+// not declare their dependencies depend on new heads.
+func DependenciesOf(condition Condition) ConditionDependencies {
+	if dependent, ok := condition.(DependentCondition); ok {
+		return dependent.Dependencies()
+	}
+	return ConditionDependencies{NewHeads: true}
+}
+
+// mergeDependencies returns the union of the dependencies of the conditions.
+func mergeDependencies(conditions []Condition) ConditionDependencies {
+	merged := ConditionDependencies{}
+	for _, condition := range conditions {
+		dependencies := DependenciesOf(condition)
+		merged.NewHeads = merged.NewHeads || dependencies.NewHeads
+		merged.Logs = append(merged.Logs, dependencies.Logs...)
+	}
+	return merged
+}
@@ -0,0 +1,80 @@
+package libeth_test
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("conditions", func() {
+
+	constant := func(ok bool, err error) libeth.Condition {
+		return libeth.ConditionFunc(func(context.Context, libeth.Client, *big.Int, *types.Receipt) (bool, error) {
+			return ok, err
+		})
+	}
+
+	check := func(condition libeth.Condition, receipt *types.Receipt) (bool, error) {
+		return condition.Check(context.Background(), libeth.Client{}, big.NewInt(1), receipt)
+	}
+
+	Context("when combining conditions", func() {
+		It("should evaluate All, Any and Not", func() {
+			errFailed := errors.New("failed")
+
+			Expect(check(libeth.All(constant(true, nil), constant(true, nil)), nil)).Should(BeTrue())
+			Expect(check(libeth.All(constant(true, nil), constant(false, nil)), nil)).Should(BeFalse())
+			_, err := check(libeth.All(constant(true, nil), constant(true, errFailed)), nil)
+			Expect(err).Should(Equal(errFailed))
+
+			Expect(check(libeth.Any(constant(false, errFailed), constant(true, nil)), nil)).Should(BeTrue())
+			_, err = check(libeth.Any(constant(false, errFailed), constant(false, nil)), nil)
+			Expect(err).Should(Equal(errFailed))
+
+			Expect(check(libeth.Not(constant(false, nil)), nil)).Should(BeTrue())
+			_, err = check(libeth.Not(constant(false, errFailed)), nil)
+			Expect(err).Should(Equal(errFailed))
+		})
+	})
+
+	Context("when declaring dependencies", func() {
+		It("should merge the dependencies of combined conditions", func() {
+			token := common.HexToAddress("0x1")
+			dependencies := libeth.DependenciesOf(libeth.All(libeth.AllowanceAtLeast(token, common.Address{}, common.Address{}, big.NewInt(1))))
+			Expect(dependencies.NewHeads).Should(BeFalse())
+			Expect(dependencies.Logs).Should(HaveLen(1))
+			Expect(dependencies.Logs[0].Addresses).Should(Equal([]common.Address{token}))
+
+			dependencies = libeth.DependenciesOf(libeth.Any(constant(true, nil), libeth.EventEmitted(token, "Transfer(address,address,uint256)")))
+			Expect(dependencies.NewHeads).Should(BeTrue())
+			Expect(dependencies.Logs).Should(HaveLen(1))
+		})
+	})
+
+	Context("when checking events", func() {
+		It("should match the event and its topics in the receipt", func() {
+			contract := common.HexToAddress("0x1")
+			from := common.BytesToHash(common.HexToAddress("0x2").Bytes())
+			receipt := &types.Receipt{
+				Logs: []*types.Log{{
+					Address: contract,
+					Topics:  []common.Hash{crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")), from, {}},
+				}},
+			}
+
+			Expect(check(libeth.EventEmitted(contract, "Transfer(address,address,uint256)"), receipt)).Should(BeTrue())
+			Expect(check(libeth.EventEmitted(contract, "Transfer(address,address,uint256)", from), receipt)).Should(BeTrue())
+			Expect(check(libeth.EventEmitted(contract, "Transfer(address,address,uint256)", common.Hash{1}), receipt)).Should(BeFalse())
+			Expect(check(libeth.EventEmitted(contract, "Approval(address,address,uint256)"), receipt)).Should(BeFalse())
+			Expect(check(libeth.EventEmitted(common.HexToAddress("0x3"), "Transfer(address,address,uint256)"), receipt)).Should(BeFalse())
+		})
+	})
+})
@@ -212,6 +212,58 @@ func (client *Client) WaitMined(ctx context.Context, tx *types.Transaction) (*ty
 	return bind.WaitMined(ctx, client.ethClient, tx)
 }
 
+// WaitConfirmations waits until the block of the transaction has been followed
+// by 'waitForBlocks' blocks. It stops waiting when the context is canceled.
+func (client *Client) WaitConfirmations(ctx context.Context, txHash common.Hash, waitForBlocks int64) error {
+	// Attempt to get block number of transaction. If context times out, an
+	// error will be returned.
+	blockNumber, err := client.TxBlockNumber(ctx, txHash.String())
+	if err != nil {
+		return err
+	}
+
+	// Attempt to get current block number. If context times out, an error will
+	// be returned.
+	currentBlockNumber, err := client.CurrentBlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Keep getting current block number, once a second, until it is greater
+	// than 'waitForBlocks' + transaction's block number. If context times
+	// out, the error is returned.
+	for big.NewInt(0).Sub(currentBlockNumber, blockNumber).Cmp(big.NewInt(waitForBlocks)) < 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+		if number, err := client.CurrentBlockNumber(ctx); err == nil {
+			currentBlockNumber = number
+		}
+	}
+	return nil
+}
+
+// Broadcast submits a signed transaction, waits for it to be mined and then
+// waits for 'confirmBlocks' blocks to be confirmed after the transaction's
+// block. Broadcasting a transaction that is already known to the node is not
+// an error, so a broadcast can safely be retried.
+func (client *Client) Broadcast(ctx context.Context, tx *types.Transaction, confirmBlocks int64) (*types.Receipt, error) {
+	if err := client.ethClient.SendTransaction(ctx, tx); err != nil && !strings.Contains(err.Error(), "known transaction") {
+		return nil, err
+	}
+
+	receipt, err := client.WaitMined(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.WaitConfirmations(ctx, tx.Hash(), confirmBlocks); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
 // Get will perform a read-only transaction on the ethereum blockchain.
 func (client *Client) Get(ctx context.Context, f func() error) (err error) {
 
@@ -386,7 +438,7 @@ func (client *Client) Relay(address, fnName string, params ...[]byte) (string, e
 		if err := json.Unmarshal(respBytes, &errObj); err != nil {
 			return "", err
 		}
-		return "", fmt.Errorf(errObj.Error)
+		return "", errors.New(errObj.Error)
 	}
 
 	respObj := struct {
@@ -0,0 +1,373 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// ErrDryRun is returned by the methods of a DryRunAccount that would hand out
+// transactions that can be sent by other means, such as prepared envelopes.
+var ErrDryRun = errors.New("not supported by a dry-run account")
+
+// errTxRecorded is returned from the signer of a dry-run transaction, to stop
+// it from being sent.
+var errTxRecorded = errors.New("transaction recorded")
+
+// DryRunTx is a transaction that a DryRunAccount has signed and simulated
+// instead of sending it. SimulationErr is the error returned by the Ethereum
+// client when simulating the transaction, which is usually a revert.
+type DryRunTx struct {
+	Tx            *types.Transaction
+	Receipt       *types.Receipt
+	ReturnData    []byte
+	SimulationErr error
+}
+
+// DryRunAccount is an Account that builds, signs and simulates transactions,
+// but records them in a log instead of sending them. Transactions are
+// simulated against the latest block, so they do not see the effects of
+// earlier transactions in the log. Post-condition checks are not evaluated,
+// because the state never changes. Nothing that the account does sends a
+// transaction, but the Ethereum client that it exposes can still be used to
+// send transactions directly.
+type DryRunAccount interface {
+	Account
+
+	// Log returns the transactions that have been recorded, in the order in
+	// which they would have been sent.
+	Log() []DryRunTx
+
+	// Reset clears the log and resets the nonce to the pending nonce of the
+	// account.
+	Reset(ctx context.Context) error
+}
+
+// dryRunAccount wraps an account, rather than embedding it, so that every
+// method of the account is either forwarded because it cannot send anything,
+// or replaced.
+type dryRunAccount struct {
+	inner *account
+
+	nonce uint64
+	log   []DryRunTx
+}
+
+// NewDryRunAccount returns a dry-run account for the provided signer which is
+// connected to an Ethereum client.
+func NewDryRunAccount(client Client, signer Signer) (DryRunAccount, error) {
+	inner, err := NewAccountWithSigner(client, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &dryRunAccount{
+		inner: inner.(*account),
+		nonce: inner.(*account).transactOpts.Nonce.Uint64(),
+		log:   []DryRunTx{},
+	}, nil
+}
+
+func (account *dryRunAccount) Client() Client {
+	return account.inner.Client()
+}
+
+func (account *dryRunAccount) EthClient() *ethclient.Client {
+	return account.inner.EthClient()
+}
+
+func (account *dryRunAccount) Address() common.Address {
+	return account.inner.Address()
+}
+
+func (account *dryRunAccount) BalanceAt(ctx context.Context, blockNumber *big.Int) (*big.Int, error) {
+	return account.inner.BalanceAt(ctx, blockNumber)
+}
+
+func (account *dryRunAccount) WriteAddress(key string, address common.Address) {
+	account.inner.WriteAddress(key, address)
+}
+
+func (account *dryRunAccount) ReadAddress(key string) (common.Address, error) {
+	return account.inner.ReadAddress(key)
+}
+
+func (account *dryRunAccount) FormatTransactionView(msg, txHash string) (string, error) {
+	return account.inner.FormatTransactionView(msg, txHash)
+}
+
+func (account *dryRunAccount) ContractTransactCtor(ctx context.Context, contractAddress common.Address, fnName string, params ...[]byte) (func(transactOpts *bind.TransactOpts) (*types.Transaction, error), error) {
+	return account.inner.ContractTransactCtor(ctx, contractAddress, fnName, params...)
+}
+
+func (account *dryRunAccount) Sign(msgHash []byte) ([]byte, error) {
+	return account.inner.Sign(msgHash)
+}
+
+func (account *dryRunAccount) SignPersonalMessage(msg []byte) ([]byte, error) {
+	return account.inner.SignPersonalMessage(msg)
+}
+
+func (account *dryRunAccount) SignTypedData(typedData TypedData) ([]byte, error) {
+	return account.inner.SignTypedData(typedData)
+}
+
+func (account *dryRunAccount) Signer() Signer {
+	return account.inner.Signer()
+}
+
+func (account *dryRunAccount) SetGasPrice(gasPrice float64) {
+	account.inner.SetGasPrice(gasPrice)
+}
+
+// Prepare returns ErrDryRun, because the envelope could be signed and
+// broadcast without the dry-run account.
+func (account *dryRunAccount) Prepare(ctx context.Context, speed TxExecutionSpeed, f func(*bind.TransactOpts) (*types.Transaction, error)) (*UnsignedTx, error) {
+	return nil, ErrDryRun
+}
+
+// PrepareTransfer returns ErrDryRun, because the envelope could be signed and
+// broadcast without the dry-run account.
+func (account *dryRunAccount) PrepareTransfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, sendAll bool) (*UnsignedTx, error) {
+	return nil, ErrDryRun
+}
+
+// ResetToPendingNonce waits for the cool down, and then resets the nonce of
+// the next recorded transaction to the pending nonce of the account, without
+// clearing the log.
+func (account *dryRunAccount) ResetToPendingNonce(ctx context.Context, coolDown time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(coolDown * time.Millisecond):
+	}
+	nonce, err := account.inner.client.EthClient().PendingNonceAt(ctx, account.Address())
+	if err != nil {
+		return err
+	}
+
+	account.inner.mu.Lock()
+	defer account.inner.mu.Unlock()
+
+	account.nonce = nonce
+	return nil
+}
+
+func (account *dryRunAccount) Log() []DryRunTx {
+	account.inner.mu.RLock()
+	defer account.inner.mu.RUnlock()
+
+	log := make([]DryRunTx, len(account.log))
+	copy(log, account.log)
+	return log
+}
+
+func (account *dryRunAccount) Reset(ctx context.Context) error {
+	nonce, err := account.inner.client.EthClient().PendingNonceAt(ctx, account.Address())
+	if err != nil {
+		return err
+	}
+
+	account.inner.mu.Lock()
+	defer account.inner.mu.Unlock()
+
+	account.nonce = nonce
+	account.log = []DryRunTx{}
+	return nil
+}
+
+// Transact signs the transaction built by 'f', simulates it and records it.
+// The returned transaction has a synthetic receipt in the log.
+func (account *dryRunAccount) Transact(ctx context.Context, speed TxExecutionSpeed, preConditionCheck func() bool, f func(*bind.TransactOpts) (*types.Transaction, error), postConditionCheck func() bool, confirmBlocks int64) (*types.Transaction, error) {
+	options := DefaultTransactOptions(speed, confirmBlocks)
+	options.PreConditionCheck = preConditionCheck
+	options.PostConditionCheck = postConditionCheck
+	return account.TransactWithOptions(ctx, f, options)
+}
+
+// TransactWithOptions signs the transaction built by 'f', simulates it and
+// records it. Retries, timeouts and confirmations of the options are ignored.
+func (account *dryRunAccount) TransactWithOptions(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error) {
+	if options.PreConditionCheck != nil && !options.PreConditionCheck() {
+		return nil, ErrPreConditionCheckFailed
+	}
+	if options.PreCondition != nil {
+		if ok, err := CheckCondition(ctx, account.inner.client, options.PreCondition, nil); err != nil || !ok {
+			return nil, ErrPreConditionCheckFailed
+		}
+	}
+
+	gasPrice, _ := SuggestedGasPrice(options.Speed)
+
+	account.inner.mu.Lock()
+	defer account.inner.mu.Unlock()
+
+	account.inner.updateGasPrice(gasPrice)
+
+	nonce := new(big.Int).SetUint64(account.nonce)
+	if options.Nonce != nil {
+		nonce = new(big.Int).Set(options.Nonce)
+	}
+
+	var signedTx *types.Transaction
+	transactor := options.transactor(account.inner.transactOpts, nonce)
+	transactor.Context = ctx
+	transactor.Signer = func(txSigner types.Signer, from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		var err error
+		if signedTx, err = account.inner.signerFn(ctx)(txSigner, from, tx); err != nil {
+			return nil, err
+		}
+		return nil, errTxRecorded
+	}
+
+	if _, err := f(transactor); err != errTxRecorded {
+		if err == nil {
+			err = errors.New("transaction was not signed by the transactor")
+		}
+		return nil, err
+	}
+
+	account.log = append(account.log, account.simulate(ctx, signedTx))
+	if signedTx.Nonce() >= account.nonce {
+		account.nonce = signedTx.Nonce() + 1
+	}
+	return signedTx, nil
+}
+
+// Transfer records a transfer of eth from the account to an ethereum address.
+func (account *dryRunAccount) Transfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, confirmBlocks int64, sendAll bool) (*types.Transaction, error) {
+	return account.TransferWithOptions(ctx, to, value, sendAll, DefaultTransactOptions(speed, confirmBlocks))
+}
+
+// TransferWithOptions records a transfer of eth from the account to an
+// ethereum address.
+func (account *dryRunAccount) TransferWithOptions(ctx context.Context, to common.Address, value *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error) {
+	options.PreConditionCheck = transferPreConditionCheck(ctx, account, value, sendAll, options.PreConditionCheck)
+	return account.TransactWithOptions(ctx, account.inner.transferTx(ctx, to, value, sendAll), options)
+}
+
+// NewERC20 returns an ERC20 whose write operations are recorded by the
+// dry-run account.
+func (account *dryRunAccount) NewERC20(addressOrAlias string, options ...ContractOption) (ERC20, error) {
+	address, err := account.inner.client.resolveContract(account.inner.addressBook, addressOrAlias, StandardERC20, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC20Detailed(address, bind.ContractBackend(account.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	client := account.Client()
+	return &erc20{
+		client:   &client,
+		account:  account,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+// NewNFT721 returns an ERC721 contract whose write operations are recorded by
+// the dry-run account.
+func (account *dryRunAccount) NewNFT721(addressOrAlias string, options ...ContractOption) (NFT721, error) {
+	address, err := account.inner.client.resolveContract(account.inner.addressBook, addressOrAlias, StandardERC721, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC721(address, bind.ContractBackend(account.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	client := account.Client()
+	return &nft721{
+		client:   &client,
+		account:  account,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+// NewToken1155 returns an ERC1155 contract whose write operations are
+// recorded by the dry-run account.
+func (account *dryRunAccount) NewToken1155(addressOrAlias string, options ...ContractOption) (Token1155, error) {
+	address, err := account.inner.client.resolveContract(account.inner.addressBook, addressOrAlias, StandardERC1155, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC1155(address, bind.ContractBackend(account.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	client := account.Client()
+	return &token1155{
+		client:   &client,
+		account:  account,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+// RepairNonces reports the nonce gaps of the account without filling them,
+// since nothing can be sent.
+func (account *dryRunAccount) RepairNonces(ctx context.Context) (NonceReport, error) {
+	report, err := account.inner.repairNonces(ctx, false)
+	report.Err = err
+	return report, err
+}
+
+// MonitorNonces reports the nonce gaps of the account on every interval until
+// the context is done, without filling them.
+func (account *dryRunAccount) MonitorNonces(ctx context.Context, interval time.Duration, reports chan<- NonceReport) error {
+	return monitorNonces(ctx, interval, reports, account.RepairNonces)
+}
+
+// simulate executes the transaction as a call against the latest block and
+// returns its log entry with a synthetic receipt. This function expects the
+// caller to hold the account's mutex.
+func (account *dryRunAccount) simulate(ctx context.Context, tx *types.Transaction) DryRunTx {
+	msg := ethereum.CallMsg{
+		From:     account.Address(),
+		To:       tx.To(),
+		Gas:      tx.Gas(),
+		GasPrice: tx.GasPrice(),
+		Value:    tx.Value(),
+		Data:     tx.Data(),
+	}
+	returnData, err := account.inner.client.EthClient().CallContract(ctx, msg, nil)
+
+	receipt := &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		TxHash:            tx.Hash(),
+		GasUsed:           tx.Gas(),
+		CumulativeGasUsed: tx.Gas(),
+		Logs:              []*types.Log{},
+	}
+	if err != nil {
+		receipt.Status = types.ReceiptStatusFailed
+	} else {
+		// The estimate is closer to the gas that would be used than the
+		// limit of the transaction
+		msg.Gas = 0
+		if gasUsed, estimateErr := account.inner.client.EthClient().EstimateGas(ctx, msg); estimateErr == nil {
+			receipt.GasUsed = gasUsed
+			receipt.CumulativeGasUsed = gasUsed
+		}
+	}
+	if tx.To() == nil {
+		receipt.ContractAddress = crypto.CreateAddress(account.Address(), tx.Nonce())
+	}
+
+	return DryRunTx{
+		Tx:            tx,
+		Receipt:       receipt,
+		ReturnData:    returnData,
+		SimulationErr: err,
+	}
+}
@@ -0,0 +1,76 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("dry-run accounts", func() {
+
+	recipient := common.HexToAddress("0xb0b")
+
+	// newDryRunAccount returns a dry-run account connected to a fake node.
+	newDryRunAccount := func() (libeth.DryRunAccount, *FakeEth, func()) {
+		server, eth := newFakeNode()
+		client, err := libeth.Connect(libeth.Localnet, server.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		key, err := crypto.GenerateKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+		Expect(err).ShouldNot(HaveOccurred())
+		return account, eth, server.Close
+	}
+
+	Context("when transacting", func() {
+		It("should record transactions in order without sending them", func() {
+			account, eth, done := newDryRunAccount()
+			defer done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			for wei := int64(1); wei <= 2; wei++ {
+				tx, err := account.TransactWithOptions(ctx, fakeTransfer(account.Client(), recipient, wei, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(tx.Nonce()).Should(Equal(uint64(wei - 1)))
+			}
+
+			log := account.Log()
+			Expect(log).Should(HaveLen(2))
+			Expect(log[1].Tx.Value()).Should(Equal(big.NewInt(2)))
+			Expect(log[1].Receipt.TxHash).Should(Equal(log[1].Tx.Hash()))
+			Expect(eth.pendingTx(0)).Should(BeNil())
+			Expect(eth.minedTxs()).Should(BeEmpty())
+
+			// Resetting the nonce keeps the log
+			Expect(account.ResetToPendingNonce(ctx, 0)).Should(Succeed())
+			tx, err := account.TransactWithOptions(ctx, fakeTransfer(account.Client(), recipient, 3, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tx.Nonce()).Should(Equal(uint64(0)))
+			Expect(account.Log()).Should(HaveLen(3))
+			Expect(eth.pendingTx(0)).Should(BeNil())
+		})
+	})
+
+	Context("when preparing envelopes", func() {
+		It("should refuse, since envelopes can be broadcast by other means", func() {
+			account, _, done := newDryRunAccount()
+			defer done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := account.Prepare(ctx, libeth.Fast, fakeTransfer(account.Client(), recipient, 1, nil))
+			Expect(err).Should(Equal(libeth.ErrDryRun))
+			_, err = account.PrepareTransfer(ctx, recipient, big.NewInt(1), libeth.Fast, false)
+			Expect(err).Should(Equal(libeth.ErrDryRun))
+			Expect(account.Log()).Should(BeEmpty())
+		})
+	})
+})
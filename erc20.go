@@ -1,9 +1,14 @@
 package libeth
 
 import (
+	"bytes"
 	"context"
 	"math/big"
+	"strings"
+	"sync"
 
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -12,27 +17,101 @@ import (
 
 type erc20 struct {
 	client   *Client
-	account  *account
+	account  Account
+	address  common.Address
 	bindings *bindings.ERC20Detailed
 }
 
+// TokenInfo is the metadata of an ERC20 token.
+type TokenInfo struct {
+	Address     common.Address
+	Name        string
+	Symbol      string
+	Decimals    int64
+	TotalSupply *big.Int
+}
+
+// tokenInfoKey identifies a token across networks.
+type tokenInfoKey struct {
+	network RenNetwork
+	address common.Address
+}
+
+// tokenInfos caches the TokenInfo of every token that has been read.
+var tokenInfos = struct {
+	mu    *sync.RWMutex
+	infos map[tokenInfoKey]TokenInfo
+}{
+	mu:    new(sync.RWMutex),
+	infos: map[tokenInfoKey]TokenInfo{},
+}
+
 type ERC20 interface {
 	ERC20View
 	Transfer(ctx context.Context, to common.Address, amount *big.Int, speed TxExecutionSpeed, sendAll bool) (*types.Transaction, error)
 	Approve(ctx context.Context, spender common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
 	TransferFrom(ctx context.Context, from, to common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
+	TransferWithOptions(ctx context.Context, to common.Address, amount *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error)
+	ApproveWithOptions(ctx context.Context, spender common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error)
+	TransferFromWithOptions(ctx context.Context, from, to common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error)
+
+	// TransferAmount and ApproveAmount take a decimal amount of the token.
+	// Amounts with more decimals than the token are rejected with
+	// ErrPrecisionLoss.
+	TransferAmount(ctx context.Context, to common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error)
+	ApproveAmount(ctx context.Context, spender common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error)
+
+	// EnsureAllowance raises the allowance of the spender to at least the
+	// minimum, only if it is below it. See AllowanceOptions.
+	EnsureAllowance(ctx context.Context, spender common.Address, min *big.Int, options AllowanceOptions) (*types.Transaction, error)
+
+	// Permit signs an EIP-2612 permit for the spender, which replaces an
+	// approve transaction on tokens that support it. SubmitPermit sends a
+	// permit signed by any owner, and PermitAndTransferFrom sends it and then
+	// uses it, when the account is the spender. Accounts with a spending policy
+	// can only sign permits of tokens whose domain cannot be reconstructed if
+	// the policy allows raw signing.
+	Permit(ctx context.Context, spender common.Address, value, deadline *big.Int) (Permit, error)
+	SubmitPermit(ctx context.Context, permit Permit, options TransactOptions) (*types.Transaction, error)
+	PermitAndTransferFrom(ctx context.Context, permit Permit, to common.Address, options TransactOptions) (*types.Transaction, error)
 }
 
 type ERC20View interface {
+	Address() common.Address
+	Name(ctx context.Context) (string, error)
+	Symbol(ctx context.Context) (string, error)
 	Decimals(ctx context.Context) (int64, error)
+	TotalSupply(ctx context.Context) (*big.Int, error)
 	BalanceOf(ctx context.Context, who common.Address) (*big.Int, error)
 	Allowance(ctx context.Context, owner, spender common.Address) (*big.Int, error)
+
+	// Info returns the metadata of the token. It is read once per token and
+	// cached, so the total supply is the one at the time of the first read.
+	Info(ctx context.Context) (TokenInfo, error)
+
+	// BalanceOfAmount and AllowanceAmount return decimal amounts of the
+	// token, using the decimals of its info.
+	BalanceOfAmount(ctx context.Context, who common.Address) (Amount, error)
+	AllowanceAmount(ctx context.Context, owner, spender common.Address) (Amount, error)
+
+	// Transfers and Approvals return the decoded events of the token within
+	// the block range, with the timestamps of their blocks. Large ranges are
+	// queried in pages.
+	Transfers(ctx context.Context, from, to []common.Address, blockRange BlockRange) ([]TransferEvent, error)
+	Approvals(ctx context.Context, owner, spender []common.Address, blockRange BlockRange) ([]ApprovalEvent, error)
+
+	// SubscribeTransfers sends the transfers that match the filter to the
+	// sink, without gaps or duplicates, until the context is done.
+	SubscribeTransfers(ctx context.Context, filter TransferFilter, sink chan<- TransferEvent) error
+
+	// SupportsPermit returns true if the token implements EIP-2612 permits.
+	SupportsPermit(ctx context.Context) (bool, error)
 }
 
-func (account *account) NewERC20(addressOrAlias string) (ERC20, error) {
-	address, ok := account.addressBook[addressOrAlias]
-	if !ok {
-		address = common.HexToAddress(addressOrAlias)
+func (account *account) NewERC20(addressOrAlias string, options ...ContractOption) (ERC20, error) {
+	address, err := account.client.resolveContract(account.addressBook, addressOrAlias, StandardERC20, options)
+	if err != nil {
+		return nil, err
 	}
 	bindings, err := bindings.NewERC20Detailed(address, bind.ContractBackend(account.EthClient()))
 	if err != nil {
@@ -42,14 +121,15 @@ func (account *account) NewERC20(addressOrAlias string) (ERC20, error) {
 	return &erc20{
 		client:   &client,
 		account:  account,
+		address:  address,
 		bindings: bindings,
 	}, nil
 }
 
-func (client *Client) NewERC20View(addressOrAlias string) (ERC20View, error) {
-	address, ok := client.addrBook[addressOrAlias]
-	if !ok {
-		address = common.HexToAddress(addressOrAlias)
+func (client *Client) NewERC20View(addressOrAlias string, options ...ContractOption) (ERC20View, error) {
+	address, err := client.resolveContract(client.addrBook, addressOrAlias, StandardERC20, options)
+	if err != nil {
+		return nil, err
 	}
 	bindings, err := bindings.NewERC20Detailed(address, bind.ContractBackend(client.EthClient()))
 	if err != nil {
@@ -57,10 +137,95 @@ func (client *Client) NewERC20View(addressOrAlias string) (ERC20View, error) {
 	}
 	return &erc20{
 		client:   client,
+		address:  address,
 		bindings: bindings,
 	}, nil
 }
 
+func (erc20 *erc20) Address() common.Address {
+	return erc20.address
+}
+
+// Name returns the name of the token. Tokens that return a bytes32 instead of
+// a string are supported.
+func (erc20 *erc20) Name(ctx context.Context) (string, error) {
+	return erc20.callString(ctx, "name")
+}
+
+// Symbol returns the symbol of the token. Tokens that return a bytes32
+// instead of a string are supported.
+func (erc20 *erc20) Symbol(ctx context.Context) (string, error) {
+	return erc20.callString(ctx, "symbol")
+}
+
+func (erc20 *erc20) TotalSupply(ctx context.Context) (*big.Int, error) {
+	var totalSupply *big.Int
+	return totalSupply, erc20.client.Get(ctx, func() error {
+		supply, err := erc20.bindings.TotalSupply(&bind.CallOpts{})
+		if err != nil {
+			return err
+		}
+		totalSupply = supply
+		return nil
+	})
+}
+
+func (erc20 *erc20) Info(ctx context.Context) (TokenInfo, error) {
+	key := tokenInfoKey{network: erc20.client.renNetwork, address: erc20.address}
+	tokenInfos.mu.RLock()
+	info, ok := tokenInfos.infos[key]
+	tokenInfos.mu.RUnlock()
+	if ok {
+		return info, nil
+	}
+
+	info = TokenInfo{Address: erc20.address}
+	var err error
+	if info.Name, err = erc20.Name(ctx); err != nil {
+		return TokenInfo{}, err
+	}
+	if info.Symbol, err = erc20.Symbol(ctx); err != nil {
+		return TokenInfo{}, err
+	}
+	if info.Decimals, err = erc20.Decimals(ctx); err != nil {
+		return TokenInfo{}, err
+	}
+	if info.TotalSupply, err = erc20.TotalSupply(ctx); err != nil {
+		return TokenInfo{}, err
+	}
+
+	tokenInfos.mu.Lock()
+	tokenInfos.infos[key] = info
+	tokenInfos.mu.Unlock()
+	return info, nil
+}
+
+// callString calls a method of the token that returns a string, or a bytes32
+// for tokens that predate the standard (e.g. DGX).
+func (erc20 *erc20) callString(ctx context.Context, method string) (string, error) {
+	parsed, err := abi.JSON(strings.NewReader(bindings.ERC20DetailedABI))
+	if err != nil {
+		return "", err
+	}
+	data, err := parsed.Pack(method)
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	return value, erc20.client.Get(ctx, func() error {
+		resp, err := erc20.client.ethClient.CallContract(ctx, ethereum.CallMsg{To: &erc20.address, Data: data}, nil)
+		if err != nil {
+			return err
+		}
+		if len(resp) == 32 {
+			value = string(bytes.TrimRight(resp, "\x00"))
+			return nil
+		}
+		return parsed.Unpack(&value, method, resp)
+	})
+}
+
 func (erc20 *erc20) Decimals(ctx context.Context) (int64, error) {
 	var decimals int64
 	return decimals, erc20.client.Get(ctx, func() error {
@@ -97,36 +262,131 @@ func (erc20 *erc20) Allowance(ctx context.Context, owner, spender common.Address
 	})
 }
 
+func (erc20 *erc20) BalanceOfAmount(ctx context.Context, who common.Address) (Amount, error) {
+	balance, err := erc20.BalanceOf(ctx, who)
+	if err != nil {
+		return Amount{}, err
+	}
+	return tokenAmount(ctx, erc20, balance)
+}
+
+func (erc20 *erc20) AllowanceAmount(ctx context.Context, owner, spender common.Address) (Amount, error) {
+	allowance, err := erc20.Allowance(ctx, owner, spender)
+	if err != nil {
+		return Amount{}, err
+	}
+	return tokenAmount(ctx, erc20, allowance)
+}
+
 func (erc20 *erc20) Transfer(ctx context.Context, to common.Address, amount *big.Int, speed TxExecutionSpeed, sendAll bool) (*types.Transaction, error) {
+	return erc20.TransferWithOptions(ctx, to, amount, sendAll, DefaultTransactOptions(speed, 1))
+}
+
+// TransferWithOptions transfers tokens from the account, as configured by the
+// options. Retries never send a second transfer while an earlier one is
+// pending or has succeeded. Once the transfer is mined, it is checked for a
+// Transfer event to the recipient, and a TransferNotExecutedError is returned
+// if it did not move any tokens. With sendAll, the balance is read again
+// before every attempt.
+func (erc20 *erc20) TransferWithOptions(ctx context.Context, to common.Address, amount *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error) {
+	from := erc20.account.Address()
 	if sendAll {
-		balance, err := erc20.BalanceOf(ctx, erc20.account.Address())
+		balance, err := erc20.BalanceOf(ctx, from)
 		if err != nil {
 			return nil, err
 		}
 		amount = balance
 	}
+	if err := erc20.simulate(ctx, "transfer", to, amount); err != nil {
+		return nil, err
+	}
+
+	min := amount
+	if sendAll {
+		min = big.NewInt(1)
+	}
+	options = erc20.transferOptions(from, to, options, TokenBalanceAtLeast(erc20.address, from, min))
 
-	return erc20.account.Transact(
+	tx, err := erc20.account.TransactWithOptions(
 		ctx,
-		speed,
-		nil,
 		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			if sendAll {
+				balance, err := erc20.bindings.BalanceOf(&bind.CallOpts{Context: tops.Context}, from)
+				if err != nil {
+					return nil, err
+				}
+				amount = balance
+			}
 			tx, err := erc20.bindings.Transfer(tops, to, amount)
 			if err != nil {
 				return tx, err
 			}
 			return tx, nil
 		},
-		nil,
-		1,
+		options,
 	)
+	if err != nil {
+		return tx, err
+	}
+	return tx, erc20.verifyTransfer(ctx, tx, from, to, amount)
+}
+
+// transferOptions returns the options of a transfer from one address to
+// another, with the pre-condition added to the pre-conditions of the options.
+// If the options have no post-condition, the post-condition is that the
+// transaction was mined without reverting. Whether it moved the tokens is
+// checked afterwards by verifyTransfer, rather than by the post-condition,
+// because a token that returns false fails the same way on every retry.
+func (erc20 *erc20) transferOptions(from, to common.Address, options TransactOptions, pre Condition) TransactOptions {
+	if options.PreCondition != nil {
+		pre = All(options.PreCondition, pre)
+	}
+	options.PreCondition = pre
+	options.Idempotent = true
+
+	if options.PostCondition != nil || options.PostConditionCheck != nil || from == to {
+		return options
+	}
+	options.PostCondition = receiptSucceeded()
+	return options
+}
+
+func (erc20 *erc20) TransferAmount(ctx context.Context, to common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error) {
+	value, err := tokenValue(ctx, erc20, amount)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.Transfer(ctx, to, value, speed, false)
 }
 
 func (erc20 *erc20) Approve(ctx context.Context, spender common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
-	return erc20.account.Transact(
+	return erc20.ApproveWithOptions(ctx, spender, amount, DefaultTransactOptions(speed, 1))
+}
+
+func (erc20 *erc20) ApproveWithOptions(ctx context.Context, spender common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error) {
+	return erc20.approve(ctx, spender, amount, options, true)
+}
+
+// approve sets the allowance of the spender. The approval is simulated first,
+// unless it depends on a transaction that is not reflected by the latest
+// block.
+func (erc20 *erc20) approve(ctx context.Context, spender common.Address, amount *big.Int, options TransactOptions, simulate bool) (*types.Transaction, error) {
+	if simulate {
+		if err := erc20.simulate(ctx, "approve", spender, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	// Unless the options have their own post-condition, the approval is only
+	// complete once the allowance has been set
+	owner := erc20.account.Address()
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		options.PostCondition = erc20.allowanceCondition(owner, spender, amount, 0, nil)
+	}
+	options.Idempotent = true
+
+	tx, err := erc20.account.TransactWithOptions(
 		ctx,
-		speed,
-		nil,
 		func(tops *bind.TransactOpts) (*types.Transaction, error) {
 			tx, err := erc20.bindings.Approve(tops, spender, amount)
 			if err != nil {
@@ -134,16 +394,37 @@ func (erc20 *erc20) Approve(ctx context.Context, spender common.Address, amount
 			}
 			return tx, nil
 		},
-		nil,
-		1,
+		options,
 	)
+	if err != nil {
+		return tx, err
+	}
+	return tx, erc20.verifyApproval(ctx, tx, owner, spender, amount)
+}
+
+func (erc20 *erc20) ApproveAmount(ctx context.Context, spender common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error) {
+	value, err := tokenValue(ctx, erc20, amount)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.Approve(ctx, spender, value, speed)
 }
 
 func (erc20 *erc20) TransferFrom(ctx context.Context, from, to common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
-	return erc20.account.Transact(
+	return erc20.TransferFromWithOptions(ctx, from, to, amount, DefaultTransactOptions(speed, 1))
+}
+
+func (erc20 *erc20) TransferFromWithOptions(ctx context.Context, from, to common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error) {
+	if err := erc20.simulate(ctx, "transferFrom", from, to, amount); err != nil {
+		return nil, err
+	}
+	options = erc20.transferOptions(from, to, options, All(
+		TokenBalanceAtLeast(erc20.address, from, amount),
+		AllowanceAtLeast(erc20.address, from, erc20.account.Address(), amount),
+	))
+
+	tx, err := erc20.account.TransactWithOptions(
 		ctx,
-		speed,
-		nil,
 		func(tops *bind.TransactOpts) (*types.Transaction, error) {
 			tx, err := erc20.bindings.TransferFrom(tops, from, to, amount)
 			if err != nil {
@@ -151,7 +432,10 @@ func (erc20 *erc20) TransferFrom(ctx context.Context, from, to common.Address, a
 			}
 			return tx, nil
 		},
-		nil,
-		1,
+		options,
 	)
+	if err != nil {
+		return tx, err
+	}
+	return tx, erc20.verifyTransfer(ctx, tx, from, to, amount)
 }
@@ -0,0 +1,342 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("erc20 tokens", func() {
+
+	selector := func(signature string) []byte {
+		return crypto.Keccak256([]byte(signature))[:4]
+	}
+
+	Context("when reading token info", func() {
+		It("should support bytes32 names and cache the info", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			token := common.HexToAddress("0xdead")
+			eth.respond(token, selector("name()"), common.RightPadBytes([]byte("Digix Gold Token"), 32))
+			eth.respond(token, selector("symbol()"), abiString("DGX"))
+			eth.respond(token, selector("decimals()"), common.LeftPadBytes([]byte{9}, 32))
+			eth.respond(token, selector("totalSupply()"), common.LeftPadBytes(big.NewInt(1000).Bytes(), 32))
+
+			erc20, err := client.NewERC20View(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			info, err := erc20.Info(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(info).Should(Equal(libeth.TokenInfo{
+				Address:     token,
+				Name:        "Digix Gold Token",
+				Symbol:      "DGX",
+				Decimals:    9,
+				TotalSupply: big.NewInt(1000),
+			}))
+
+			eth.respond(token, selector("symbol()"), abiString("DGD"))
+			info, err = erc20.Info(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(info.Symbol).Should(Equal("DGX"))
+			Expect(erc20.Symbol(ctx)).Should(Equal("DGD"))
+		})
+	})
+
+	Context("when reading event history", func() {
+		It("should page and split block ranges and return timestamps", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			token := common.HexToAddress("0xbeef")
+			alice, bob := common.HexToAddress("0xa11ce"), common.HexToAddress("0xb0b")
+			transferID := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+			approvalID := crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+			event := func(id common.Hash, from, to common.Address, value int64, block uint64) types.Log {
+				return types.Log{
+					Address:     token,
+					Topics:      []common.Hash{id, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+					Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+					BlockNumber: block,
+					TxHash:      common.BigToHash(big.NewInt(int64(block))),
+				}
+			}
+			eth.logs = []types.Log{
+				event(transferID, alice, bob, 1, 3),
+				event(transferID, bob, alice, 2, 7000),
+				event(approvalID, alice, bob, 3, 7001),
+				event(transferID, alice, bob, 4, 12000),
+			}
+			eth.head = 12000
+			eth.maxLogRange = 2000
+
+			erc20, err := client.NewERC20View(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			transfers, err := erc20.Transfers(ctx, []common.Address{alice}, nil, libeth.BlockRange{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(transfers).Should(HaveLen(2))
+			Expect(transfers[0].To).Should(Equal(bob))
+			Expect(transfers[0].Value).Should(Equal(big.NewInt(1)))
+			Expect(transfers[0].Timestamp).Should(Equal(time.Unix(30, 0)))
+			Expect(transfers[1].BlockNumber).Should(Equal(uint64(12000)))
+			// Three pages of 5000 blocks, each rejected and split
+			Expect(eth.logQueries).Should(BeNumerically(">", 3))
+
+			approvals, err := erc20.Approvals(ctx, nil, []common.Address{bob}, libeth.BlockRange{From: big.NewInt(7000), To: big.NewInt(7001)})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(approvals).Should(HaveLen(1))
+			Expect(approvals[0].Owner).Should(Equal(alice))
+			Expect(approvals[0].Value).Should(Equal(big.NewInt(3)))
+		})
+	})
+
+	Context("when subscribing to transfers", func() {
+		It("should deliver confirmed transfers once and in order", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			token := common.HexToAddress("0xbeef")
+			alice, bob := common.HexToAddress("0xa11ce"), common.HexToAddress("0xb0b")
+			transferID := crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+			transfer := func(value int64, block uint64) types.Log {
+				return types.Log{
+					Address:     token,
+					Topics:      []common.Hash{transferID, common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes())},
+					Data:        common.LeftPadBytes(big.NewInt(value).Bytes(), 32),
+					BlockNumber: block,
+					TxHash:      common.BigToHash(big.NewInt(value)),
+				}
+			}
+			eth.mu.Lock()
+			eth.logs = []types.Log{transfer(1, 5), transfer(2, 9)}
+			eth.head = 10
+			eth.mu.Unlock()
+
+			erc20, err := client.NewERC20View(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			sink := make(chan libeth.TransferEvent)
+			done := make(chan error, 1)
+			go func() {
+				done <- erc20.SubscribeTransfers(ctx, libeth.TransferFilter{
+					To:            []common.Address{bob},
+					FromBlock:     big.NewInt(0),
+					Confirmations: 2,
+					PollInterval:  10 * time.Millisecond,
+				}, sink)
+			}()
+
+			// The transfer at block 9 is not confirmed yet
+			Expect((<-sink).Value).Should(Equal(big.NewInt(1)))
+			Consistently(sink, 100*time.Millisecond).ShouldNot(Receive())
+
+			eth.mu.Lock()
+			eth.logs = append(eth.logs, transfer(3, 11))
+			eth.head = 13
+			eth.mu.Unlock()
+			Expect((<-sink).Value).Should(Equal(big.NewInt(2)))
+			Expect((<-sink).Value).Should(Equal(big.NewInt(3)))
+			Consistently(sink, 100*time.Millisecond).ShouldNot(Receive())
+
+			cancel()
+			Eventually(done).Should(Receive(Equal(context.Canceled)))
+		})
+		It("should send the errors that make it reconnect to the error channel", func() {
+			server, _ := newFakeNode()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			erc20, err := client.NewERC20View(common.HexToAddress("0xbeef").Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			server.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			errs := make(chan error)
+			go erc20.SubscribeTransfers(ctx, libeth.TransferFilter{
+				FromBlock: big.NewInt(0),
+				Errors:    errs,
+			}, make(chan libeth.TransferEvent))
+			Eventually(errs, 5*time.Second).Should(Receive(HaveOccurred()))
+		})
+	})
+
+	Context("when transferring tokens", func() {
+		It("should complete once the transfer event is emitted, even if the balance does not increase", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Other transfers keep the balance of the recipient the same
+			token := common.HexToAddress("0xbeef")
+			bob := common.HexToAddress("0xb0b")
+			eth.respond(token, selector("transfer(address,uint256)"), common.LeftPadBytes([]byte{1}, 32))
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes([]byte{100}, 32))
+			eth.mineWithLogs(ctx, 0, &types.Log{
+				Address: token,
+				Topics:  []common.Hash{crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")), common.BytesToHash(account.Address().Bytes()), common.BytesToHash(bob.Bytes())},
+				Data:    common.LeftPadBytes([]byte{10}, 32),
+			})
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.PostConditionTimeout = 2 * time.Second
+			_, err = erc20.TransferWithOptions(ctx, bob, big.NewInt(10), false, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(eth.minedTxs()).Should(HaveLen(1))
+		})
+
+		It("should return an error instead of retrying a mined transfer that emits no event", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The token returns nothing, and the transfer is mined without
+			// moving any tokens
+			token := common.HexToAddress("0xbeef")
+			bob := common.HexToAddress("0xb0b")
+			eth.respond(token, selector("transfer(address,uint256)"), []byte{})
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes([]byte{100}, 32))
+			eth.mineWithLogs(ctx, 0)
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.PostConditionTimeout = 2 * time.Second
+			tx, err := erc20.TransferWithOptions(ctx, bob, big.NewInt(10), false, options)
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.TransferNotExecutedError{}))
+			Expect(err.(*libeth.TransferNotExecutedError).TxHash).Should(Equal(tx.Hash()))
+			Expect(eth.minedTxs()).Should(HaveLen(1))
+		})
+	})
+
+	Context("when a token does not return a bool", func() {
+		newToken := func(transferResponse []byte) (libeth.ERC20, libeth.DryRunAccount, func()) {
+			server, eth := newFakeNode()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			token := common.HexToAddress("0xd26114cd6ee289accf82350c8d8487fedb8a0c07")
+			eth.respond(token, selector("transfer(address,uint256)"), transferResponse)
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes([]byte{100}, 32))
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			return erc20, account, server.Close
+		}
+
+		It("should accept tokens that return nothing", func() {
+			erc20, account, closeServer := newToken([]byte{})
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := erc20.Transfer(ctx, common.HexToAddress("0xb0b"), big.NewInt(1), libeth.Fast, false)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(account.Log()).Should(HaveLen(1))
+		})
+
+		It("should not transfer more than the balance", func() {
+			erc20, account, closeServer := newToken([]byte{})
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := erc20.Transfer(ctx, common.HexToAddress("0xb0b"), big.NewInt(101), libeth.Fast, false)
+			Expect(err).Should(Equal(libeth.ErrPreConditionCheckFailed))
+			Expect(account.Log()).Should(BeEmpty())
+		})
+
+		It("should reject transfers that would return false", func() {
+			erc20, account, closeServer := newToken(common.LeftPadBytes([]byte{0}, 32))
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := erc20.Transfer(ctx, common.HexToAddress("0xb0b"), big.NewInt(1), libeth.Fast, false)
+			Expect(err).Should(Equal(libeth.ErrTokenReturnedFalse))
+			Expect(account.Log()).Should(BeEmpty())
+		})
+
+		// transferAndCredit mines a transfer of ten tokens, of a token that
+		// returns nothing and emits no events, in block 1, during which the
+		// balance of the recipient increases by the credit.
+		transferAndCredit := func(credit int64) error {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			token := common.HexToAddress("0xd26114cd6ee289accf82350c8d8487fedb8a0c07")
+			bob := common.HexToAddress("0xb0b")
+			balanceOfBob := append(selector("balanceOf(address)"), common.LeftPadBytes(bob.Bytes(), 32)...)
+			eth.respond(token, selector("transfer(address,uint256)"), []byte{})
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes([]byte{100}, 32))
+			eth.respondAt(token, balanceOfBob, 0, common.LeftPadBytes(big.NewInt(100).Bytes(), 32))
+			eth.respondAt(token, balanceOfBob, 1, common.LeftPadBytes(big.NewInt(100+credit).Bytes(), 32))
+			eth.mineWithLogs(ctx, 0)
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.PostConditionTimeout = 2 * time.Second
+			_, err = erc20.TransferWithOptions(ctx, bob, big.NewInt(10), false, options)
+			Expect(eth.minedTxs()).Should(HaveLen(1))
+			return err
+		}
+
+		It("should accept a mined transfer that credits the recipient with the value", func() {
+			Expect(transferAndCredit(10)).Should(Succeed())
+		})
+
+		It("should reject a mined transfer that does not credit the recipient with the value", func() {
+			Expect(transferAndCredit(0)).Should(BeAssignableToTypeOf(&libeth.TransferNotExecutedError{}))
+			Expect(transferAndCredit(30)).Should(BeAssignableToTypeOf(&libeth.TransferNotExecutedError{}))
+		})
+	})
+})
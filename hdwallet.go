@@ -0,0 +1,230 @@
+package libeth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// ErrInvalidMnemonic indicates that the mnemonic is not a valid BIP-39
+// mnemonic.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// ErrInvalidChildKey indicates that a derivation step produced an invalid key.
+// BIP-32 recommends proceeding with the next index when this happens.
+var ErrInvalidChildKey = errors.New("invalid child key")
+
+// DefaultHDBasePath is the BIP-44 path of the external chain of the first
+// Ethereum account. Account i is derived at DefaultHDBasePath/i.
+const DefaultHDBasePath = "m/44'/60'/0'/0"
+
+// hardenedKeyStart is the index of the first hardened child key.
+const hardenedKeyStart = 0x80000000
+
+// HDWallet derives keys, Signers and Accounts from a single BIP-39 seed along
+// BIP-32 paths, so that one backed-up mnemonic covers any number of accounts.
+type HDWallet struct {
+	seed     []byte
+	basePath accounts.DerivationPath
+}
+
+// HDAddress is an address derived by an HDWallet that has been used on
+// chain.
+type HDAddress struct {
+	Index   uint32
+	Address common.Address
+	Nonce   uint64
+	Balance *big.Int
+}
+
+// NewMnemonic returns a new random BIP-39 mnemonic with the given number of
+// bits of entropy, which must be a multiple of 32 between 128 and 256.
+func NewMnemonic(bitSize int) (string, error) {
+	entropy, err := bip39.NewEntropy(bitSize)
+	if err != nil {
+		return "", err
+	}
+	return bip39.NewMnemonic(entropy)
+}
+
+// NewHDWallet returns an HDWallet for the given mnemonic and optional BIP-39
+// password. Keys are derived from the base path, which defaults to
+// DefaultHDBasePath when empty.
+func NewHDWallet(mnemonic, password, basePath string) (*HDWallet, error) {
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, password)
+	if err != nil {
+		return nil, ErrInvalidMnemonic
+	}
+	return NewHDWalletFromSeed(seed, basePath)
+}
+
+// NewHDWalletFromSeed returns an HDWallet for the given BIP-32 seed. Keys are
+// derived from the base path, which defaults to DefaultHDBasePath when empty.
+func NewHDWalletFromSeed(seed []byte, basePath string) (*HDWallet, error) {
+	if basePath == "" {
+		basePath = DefaultHDBasePath
+	}
+	path, err := accounts.ParseDerivationPath(basePath)
+	if err != nil {
+		return nil, err
+	}
+	return &HDWallet{
+		seed:     common.CopyBytes(seed),
+		basePath: path,
+	}, nil
+}
+
+// DeriveKey returns the private key at the given absolute derivation path.
+func (wallet *HDWallet) DeriveKey(path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
+	key, chainCode := hdMasterKey(wallet.seed)
+	for _, index := range path {
+		var err error
+		if key, chainCode, err = hdChildKey(key, chainCode, index); err != nil {
+			return nil, err
+		}
+	}
+	return crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+}
+
+// Path returns the derivation path of the account with the given index.
+func (wallet *HDWallet) Path(index uint32) accounts.DerivationPath {
+	path := make(accounts.DerivationPath, len(wallet.basePath), len(wallet.basePath)+1)
+	copy(path, wallet.basePath)
+	return append(path, index)
+}
+
+// Signer returns a Signer for the account with the given index.
+func (wallet *HDWallet) Signer(index uint32) (Signer, error) {
+	key, err := wallet.DeriveKey(wallet.Path(index))
+	if err != nil {
+		return nil, err
+	}
+	return NewPrivateKeySigner(key), nil
+}
+
+// Address returns the address of the account with the given index.
+func (wallet *HDWallet) Address(index uint32) (common.Address, error) {
+	signer, err := wallet.Signer(index)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return signer.Address(), nil
+}
+
+// Account returns the Account with the given index which is connected to the
+// client.
+func (wallet *HDWallet) Account(client Client, index uint32) (Account, error) {
+	signer, err := wallet.Signer(index)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountWithSigner(client, signer)
+}
+
+// Accounts returns n consecutive Accounts, starting from the given index,
+// which are connected to the client.
+func (wallet *HDWallet) Accounts(client Client, from, n uint32) ([]Account, error) {
+	accs := make([]Account, 0, n)
+	for index := from; index < from+n; index++ {
+		account, err := wallet.Account(client, index)
+		if err != nil {
+			return nil, err
+		}
+		accs = append(accs, account)
+	}
+	return accs, nil
+}
+
+// Discover scans the accounts of the wallet in order and returns the ones that
+// have a non-zero nonce or balance. The scan stops once gapLimit consecutive
+// unused accounts have been seen.
+func (wallet *HDWallet) Discover(ctx context.Context, client Client, gapLimit uint32) ([]HDAddress, error) {
+	used := []HDAddress{}
+	for index, gap := uint32(0), uint32(0); gap < gapLimit; index++ {
+		address, err := wallet.Address(index)
+		if err != nil {
+			if err == ErrInvalidChildKey {
+				continue
+			}
+			return nil, err
+		}
+
+		var nonce uint64
+		var balance *big.Int
+		if err := client.Get(ctx, func() (err error) {
+			if nonce, err = client.EthClient().NonceAt(ctx, address, nil); err != nil {
+				return
+			}
+			balance, err = client.EthClient().BalanceAt(ctx, address, nil)
+			return
+		}); err != nil {
+			return nil, err
+		}
+
+		if nonce == 0 && balance.Sign() == 0 {
+			gap++
+			continue
+		}
+		gap = 0
+		used = append(used, HDAddress{
+			Index:   index,
+			Address: address,
+			Nonce:   nonce,
+			Balance: balance,
+		})
+	}
+	return used, nil
+}
+
+// hdMasterKey returns the BIP-32 master key and chain code for the seed.
+func hdMasterKey(seed []byte) (*big.Int, []byte) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	return new(big.Int).SetBytes(sum[:32]), sum[32:]
+}
+
+// hdChildKey returns the BIP-32 private child key and chain code at the given
+// index.
+func hdChildKey(key *big.Int, chainCode []byte, index uint32) (*big.Int, []byte, error) {
+	data := make([]byte, 0, 37)
+	if index >= hardenedKeyStart {
+		data = append(data, 0x00)
+		data = append(data, math.PaddedBigBytes(key, 32)...)
+	} else {
+		privateKey, err := crypto.ToECDSA(math.PaddedBigBytes(key, 32))
+		if err != nil {
+			return nil, nil, ErrInvalidChildKey
+		}
+		data = append(data, crypto.CompressPubkey(&privateKey.PublicKey)...)
+	}
+	var indexBytes [4]byte
+	binary.BigEndian.PutUint32(indexBytes[:], index)
+	data = append(data, indexBytes[:]...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	tweak := new(big.Int).SetBytes(sum[:32])
+	if tweak.Cmp(n) >= 0 {
+		return nil, nil, ErrInvalidChildKey
+	}
+	child := tweak.Add(tweak, key)
+	child.Mod(child, n)
+	if child.Sign() == 0 {
+		return nil, nil, ErrInvalidChildKey
+	}
+	return child, sum[32:], nil
+}
@@ -0,0 +1,71 @@
+package libeth_test
+
+import (
+	"encoding/hex"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("hd wallets", func() {
+
+	Context("when deriving keys from a seed", func() {
+		It("should match the BIP-32 test vectors", func() {
+			seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+			Expect(err).ShouldNot(HaveOccurred())
+			wallet, err := libeth.NewHDWalletFromSeed(seed, "")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			vectors := map[string]string{
+				"m":                      "e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+				"m/0'":                   "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+				"m/0'/1":                 "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+				"m/0'/1/2'":              "cbce0d719ecf7431d88e6a89fa1483e02e35092af60c042b1df2ff59fa424dca",
+				"m/0'/1/2'/2/1000000000": "471b76e389e528d6de6d816857e012c5455051cad6660850e58372a6c3e6e7c8",
+			}
+			for path, expected := range vectors {
+				var derivationPath accounts.DerivationPath
+				if path != "m" {
+					derivationPath, err = accounts.ParseDerivationPath(path)
+					Expect(err).ShouldNot(HaveOccurred())
+				}
+				key, err := wallet.DeriveKey(derivationPath)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(hex.EncodeToString(crypto.FromECDSA(key))).Should(Equal(expected))
+			}
+		})
+	})
+
+	Context("when deriving accounts from a mnemonic", func() {
+		It("should derive the standard Ethereum addresses", func() {
+			mnemonic := strings.Repeat("abandon ", 11) + "about"
+			wallet, err := libeth.NewHDWallet(mnemonic, "", "")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			address, err := wallet.Address(0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(address.Hex()).Should(Equal("0x9858EfFD232B4033E47d90003D41EC34EcaEda94"))
+			address, err = wallet.Address(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(address.Hex()).Should(Equal("0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"))
+		})
+
+		It("should reject invalid mnemonics", func() {
+			_, err := libeth.NewHDWallet(strings.Repeat("abandon ", 12), "", "")
+			Expect(err).Should(Equal(libeth.ErrInvalidMnemonic))
+		})
+
+		It("should generate valid mnemonics", func() {
+			mnemonic, err := libeth.NewMnemonic(256)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(strings.Fields(mnemonic)).Should(HaveLen(24))
+			_, err = libeth.NewHDWallet(mnemonic, "password", "")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
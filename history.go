@@ -0,0 +1,232 @@
+package libeth
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// LogPageSize is the number of blocks queried at once when reading event
+// history. Pages are split further if the provider rejects them.
+const LogPageSize = 5000
+
+// BlockRange is an inclusive range of blocks. A nil From starts at the genesis
+// block, and a nil To ends at the latest block.
+type BlockRange struct {
+	From *big.Int
+	To   *big.Int
+}
+
+// EventMeta locates an event on the blockchain.
+type EventMeta struct {
+	BlockNumber uint64
+	BlockHash   common.Hash
+	TxHash      common.Hash
+	LogIndex    uint
+	Timestamp   time.Time
+}
+
+// TransferEvent is a decoded ERC20 Transfer event.
+type TransferEvent struct {
+	EventMeta
+	From  common.Address
+	To    common.Address
+	Value *big.Int
+}
+
+// ApprovalEvent is a decoded ERC20 Approval event.
+type ApprovalEvent struct {
+	EventMeta
+	Owner   common.Address
+	Spender common.Address
+	Value   *big.Int
+}
+
+// Transfers returns the transfers between the addresses within the block
+// range, oldest first. Empty from or to addresses match any address.
+func (erc20 *erc20) Transfers(ctx context.Context, from, to []common.Address, blockRange BlockRange) ([]TransferEvent, error) {
+	events := []TransferEvent{}
+	err := erc20.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := erc20.bindings.FilterTransfer(opts, from, to)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []TransferEvent{}
+		for iter.Next() {
+			page = append(page, TransferEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				From:      iter.Event.From,
+				To:        iter.Event.To,
+				Value:     iter.Event.Value,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(erc20.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Approvals returns the approvals from the owners to the spenders within the
+// block range, oldest first. Empty owner or spender addresses match any
+// address.
+func (erc20 *erc20) Approvals(ctx context.Context, owner, spender []common.Address, blockRange BlockRange) ([]ApprovalEvent, error) {
+	events := []ApprovalEvent{}
+	err := erc20.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := erc20.bindings.FilterApproval(opts, owner, spender)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []ApprovalEvent{}
+		for iter.Next() {
+			page = append(page, ApprovalEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				Owner:     iter.Event.Owner,
+				Spender:   iter.Event.Spender,
+				Value:     iter.Event.Value,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(erc20.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// eventMeta returns the location of the log. Its timestamp is read
+// separately, once per block.
+func eventMeta(log types.Log) EventMeta {
+	return EventMeta{
+		BlockNumber: log.BlockNumber,
+		BlockHash:   log.BlockHash,
+		TxHash:      log.TxHash,
+		LogIndex:    log.Index,
+	}
+}
+
+// filterPaged calls filter for consecutive pages of the block range. A page
+// that the provider rejects as too large is split in two and retried, until
+// it is a single block.
+func (client *Client) filterPaged(ctx context.Context, blockRange BlockRange, filter func(*bind.FilterOpts) error) error {
+	var from, to uint64
+	if blockRange.From != nil {
+		from = blockRange.From.Uint64()
+	}
+	if blockRange.To != nil {
+		to = blockRange.To.Uint64()
+	} else {
+		err := client.Get(ctx, func() error {
+			header, err := client.ethClient.HeaderByNumber(ctx, nil)
+			if err != nil {
+				return err
+			}
+			to = header.Number.Uint64()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var filterRange func(start, end uint64) error
+	filterRange = func(start, end uint64) error {
+		err := filter(&bind.FilterOpts{Start: start, End: &end, Context: ctx})
+		if err == nil || start == end || !isRangeTooLarge(err) {
+			return err
+		}
+		mid := start + (end-start)/2
+		if err := filterRange(start, mid); err != nil {
+			return err
+		}
+		return filterRange(mid+1, end)
+	}
+
+	for start := from; start <= to; start += LogPageSize {
+		end := start + LogPageSize - 1
+		if end > to {
+			end = to
+		}
+		if err := filterRange(start, end); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isRangeTooLarge returns true if the error is a provider rejecting a log
+// query because of the size of its block range or of its result.
+func isRangeTooLarge(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, marker := range []string{"more than", "too large", "too many", "too wide", "block range", "limit exceeded", "response size"} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockTimestamps reads and caches the timestamps of blocks.
+type blockTimestamps struct {
+	client     *Client
+	timestamps map[uint64]time.Time
+}
+
+func newBlockTimestamps(client *Client) *blockTimestamps {
+	return &blockTimestamps{
+		client:     client,
+		timestamps: map[uint64]time.Time{},
+	}
+}
+
+func (timestamps *blockTimestamps) get(ctx context.Context, block uint64) (time.Time, error) {
+	if timestamp, ok := timestamps.timestamps[block]; ok {
+		return timestamp, nil
+	}
+	var timestamp time.Time
+	err := timestamps.client.Get(ctx, func() error {
+		header, err := timestamps.client.ethClient.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+		if err != nil {
+			return err
+		}
+		timestamp = time.Unix(header.Time.Int64(), 0)
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	timestamps.timestamps[block] = timestamp
+	return timestamp, nil
+}
@@ -0,0 +1,273 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// ErrInvalidAddress is returned when an address is neither in the address
+// book nor a hex address.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ErrNotContract is returned when there is no code at an address.
+var ErrNotContract = errors.New("no contract at address")
+
+// TokenStandard is a token standard that an address can be inspected for.
+type TokenStandard string
+
+// Token standards.
+const (
+	StandardERC20   = TokenStandard("ERC20")
+	StandardERC721  = TokenStandard("ERC721")
+	StandardERC1155 = TokenStandard("ERC1155")
+)
+
+// ERC165 interface IDs.
+var (
+	InterfaceERC165             = [4]byte{0x01, 0xff, 0xc9, 0xa7}
+	InterfaceERC721             = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	InterfaceERC721Metadata     = [4]byte{0x5b, 0x5e, 0x13, 0x9f}
+	InterfaceERC721Enumerable   = [4]byte{0x78, 0x0e, 0x9d, 0x63}
+	InterfaceERC1155            = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+	InterfaceERC1155MetadataURI = [4]byte{0x0e, 0x89, 0x34, 0x1c}
+)
+
+var erc721ABI, _ = abi.JSON(strings.NewReader(bindings.ERC721ABI))
+
+// eip1967ImplementationSlot is the storage slot in which EIP-1967 proxies keep
+// the address of their implementation, keccak256("eip1967.proxy.implementation") - 1.
+var eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+// Inspection describes what is deployed at an address.
+type Inspection struct {
+	Address common.Address
+
+	// HasCode is false for externally owned accounts, and for contracts that
+	// have not been deployed yet or have self-destructed.
+	HasCode bool
+
+	// ERC165 is true if the contract implements supportsInterface as
+	// specified, and Interfaces holds its answer for every known interface ID.
+	ERC165     bool
+	Interfaces map[[4]byte]bool
+
+	// ERC20 is a heuristic, since ERC20 predates ERC165. It is true if
+	// totalSupply, balanceOf and allowance all return a single word, and the
+	// contract is not an ERC721 or ERC1155 contract.
+	ERC20 bool
+
+	// Implementation is the address in the EIP-1967 implementation slot of a
+	// proxy, or nil if the slot is empty. The other results are those of the
+	// proxy, and so of its implementation.
+	Implementation *common.Address
+}
+
+// Supports returns true if the contract reported that it supports the
+// ERC165 interface ID.
+func (inspection Inspection) Supports(interfaceID [4]byte) bool {
+	return inspection.Interfaces[interfaceID]
+}
+
+// Is returns true if the contract implements the token standard.
+func (inspection Inspection) Is(standard TokenStandard) bool {
+	switch standard {
+	case StandardERC20:
+		return inspection.ERC20
+	case StandardERC721:
+		return inspection.Supports(InterfaceERC721)
+	case StandardERC1155:
+		return inspection.Supports(InterfaceERC1155)
+	}
+	return false
+}
+
+// Standards returns the token standards that the contract implements.
+func (inspection Inspection) Standards() []TokenStandard {
+	standards := []TokenStandard{}
+	for _, standard := range []TokenStandard{StandardERC20, StandardERC721, StandardERC1155} {
+		if inspection.Is(standard) {
+			standards = append(standards, standard)
+		}
+	}
+	return standards
+}
+
+// StandardMismatchError is returned when a contract is validated against a
+// token standard that it does not implement.
+type StandardMismatchError struct {
+	Expected   TokenStandard
+	Inspection Inspection
+}
+
+func (err *StandardMismatchError) Error() string {
+	return fmt.Sprintf("contract at %v is not an %v contract (implements %v)", err.Inspection.Address.Hex(), err.Expected, err.Inspection.Standards())
+}
+
+// Inspect reports what is deployed at the address: whether it has code, the
+// ERC165 interfaces it supports, whether it looks like an ERC20 token and
+// the implementation it delegates to if it is an EIP-1967 proxy. Calls that
+// revert count as unsupported, but other errors are returned.
+func (client *Client) Inspect(ctx context.Context, address common.Address) (Inspection, error) {
+	inspection := Inspection{
+		Address:    address,
+		Interfaces: map[[4]byte]bool{},
+	}
+
+	var code []byte
+	if err := client.Get(ctx, func() (err error) {
+		code, err = client.ethClient.CodeAt(ctx, address, nil)
+		return err
+	}); err != nil {
+		return Inspection{}, err
+	}
+	inspection.HasCode = len(code) > 0
+	if !inspection.HasCode {
+		return inspection, nil
+	}
+
+	var slot []byte
+	if err := client.Get(ctx, func() (err error) {
+		slot, err = client.ethClient.StorageAt(ctx, address, eip1967ImplementationSlot, nil)
+		return err
+	}); err != nil {
+		return Inspection{}, err
+	}
+	if implementation := common.BytesToAddress(slot); implementation != (common.Address{}) {
+		inspection.Implementation = &implementation
+	}
+
+	// A contract supports ERC165 if it claims the ERC165 interface and denies
+	// the invalid interface ID, which rules out fallbacks that return true
+	// for anything
+	supports, err := client.supportsInterface(ctx, address, InterfaceERC165)
+	if err != nil {
+		return Inspection{}, err
+	}
+	if supports {
+		invalid, err := client.supportsInterface(ctx, address, [4]byte{0xff, 0xff, 0xff, 0xff})
+		if err != nil {
+			return Inspection{}, err
+		}
+		inspection.ERC165 = !invalid
+	}
+	if inspection.ERC165 {
+		for _, interfaceID := range [][4]byte{InterfaceERC165, InterfaceERC721, InterfaceERC721Metadata, InterfaceERC721Enumerable, InterfaceERC1155, InterfaceERC1155MetadataURI} {
+			if inspection.Interfaces[interfaceID], err = client.supportsInterface(ctx, address, interfaceID); err != nil {
+				return Inspection{}, err
+			}
+		}
+	}
+
+	if inspection.Supports(InterfaceERC721) || inspection.Supports(InterfaceERC1155) {
+		return inspection, nil
+	}
+	inspection.ERC20 = true
+	for _, args := range [][]interface{}{
+		{"totalSupply"},
+		{"balanceOf", common.Address{}},
+		{"allowance", common.Address{}, common.Address{}},
+	} {
+		data, err := erc20ABI.Pack(args[0].(string), args[1:]...)
+		if err != nil {
+			return Inspection{}, err
+		}
+		resp, ok, err := client.probe(ctx, address, data)
+		if err != nil {
+			return Inspection{}, err
+		}
+		if !ok || len(resp) != 32 {
+			inspection.ERC20 = false
+			break
+		}
+	}
+	return inspection, nil
+}
+
+// supportsInterface returns true if the contract returns true from
+// supportsInterface for the interface ID.
+func (client *Client) supportsInterface(ctx context.Context, address common.Address, interfaceID [4]byte) (bool, error) {
+	data, err := erc721ABI.Pack("supportsInterface", interfaceID)
+	if err != nil {
+		return false, err
+	}
+	resp, ok, err := client.probe(ctx, address, data)
+	if err != nil || !ok {
+		return false, err
+	}
+	return len(resp) == 32 && resp[31] == 1, nil
+}
+
+// probe calls the contract, and returns false instead of an error if the call
+// reverts.
+func (client *Client) probe(ctx context.Context, address common.Address, data []byte) ([]byte, bool, error) {
+	var resp []byte
+	reverted := false
+	err := client.Get(ctx, func() (err error) {
+		resp, err = client.ethClient.CallContract(ctx, ethereum.CallMsg{To: &address, Data: data}, nil)
+		if err != nil && isRevert(err) {
+			reverted = true
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return resp, !reverted, nil
+}
+
+// ContractOption configures the New* constructors of contracts.
+type ContractOption func(*contractOptions)
+
+type contractOptions struct {
+	validateCtx context.Context
+}
+
+// Validate makes a New* constructor inspect its target within the context,
+// and return an error if the address is not valid hex, has no code, or is
+// not a contract of the expected standard.
+func Validate(ctx context.Context) ContractOption {
+	return func(options *contractOptions) {
+		options.validateCtx = ctx
+	}
+}
+
+// resolveContract returns the address of the alias in the address book, or
+// the hex address otherwise, validated against the standard if the options
+// ask for it.
+func (client *Client) resolveContract(addressBook AddressBook, addressOrAlias string, standard TokenStandard, opts []ContractOption) (common.Address, error) {
+	options := contractOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	address, ok := addressBook[addressOrAlias]
+	if !ok {
+		if options.validateCtx != nil && !common.IsHexAddress(addressOrAlias) {
+			return common.Address{}, ErrInvalidAddress
+		}
+		address = common.HexToAddress(addressOrAlias)
+	}
+	if options.validateCtx == nil {
+		return address, nil
+	}
+
+	inspection, err := client.Inspect(options.validateCtx, address)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !inspection.HasCode {
+		return common.Address{}, ErrNotContract
+	}
+	if !inspection.Is(standard) {
+		return common.Address{}, &StandardMismatchError{Expected: standard, Inspection: inspection}
+	}
+	return address, nil
+}
@@ -0,0 +1,125 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("inspecting addresses", func() {
+
+	contract := common.HexToAddress("0xc0de")
+
+	selector := func(signature string) []byte {
+		return crypto.Keccak256([]byte(signature))[:4]
+	}
+	word := func(value int64) []byte {
+		return common.LeftPadBytes(big.NewInt(value).Bytes(), 32)
+	}
+	supportsInterface := func(interfaceID [4]byte) []byte {
+		return append(selector("supportsInterface(bytes4)"), common.RightPadBytes(interfaceID[:], 32)...)
+	}
+
+	// inspect connects to a fake node set up by the function, and inspects
+	// the contract.
+	inspect := func(setup func(eth *FakeEth)) libeth.Inspection {
+		server, eth := newFakeNode()
+		defer server.Close()
+		setup(eth)
+		client, err := libeth.Connect(libeth.Localnet, server.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		inspection, err := client.Inspect(ctx, contract)
+		Expect(err).ShouldNot(HaveOccurred())
+		return inspection
+	}
+
+	erc20 := func(eth *FakeEth) {
+		eth.respond(contract, selector("totalSupply()"), word(100))
+		eth.respond(contract, selector("balanceOf(address)"), word(0))
+		eth.respond(contract, selector("allowance(address,address)"), word(0))
+	}
+
+	Context("when there is no code at the address", func() {
+		It("should report an externally owned account", func() {
+			inspection := inspect(func(*FakeEth) {})
+			Expect(inspection.HasCode).Should(BeFalse())
+			Expect(inspection.Standards()).Should(BeEmpty())
+		})
+	})
+
+	Context("when the address is an ERC20 token", func() {
+		It("should detect it without ERC165", func() {
+			inspection := inspect(erc20)
+			Expect(inspection.HasCode).Should(BeTrue())
+			Expect(inspection.ERC165).Should(BeFalse())
+			Expect(inspection.ERC20).Should(BeTrue())
+			Expect(inspection.Standards()).Should(Equal([]libeth.TokenStandard{libeth.StandardERC20}))
+			Expect(inspection.Implementation).Should(BeNil())
+		})
+
+		It("should not detect a contract that returns true for any interface", func() {
+			inspection := inspect(func(eth *FakeEth) {
+				erc20(eth)
+				eth.respond(contract, selector("supportsInterface(bytes4)"), word(1))
+			})
+			Expect(inspection.ERC165).Should(BeFalse())
+			Expect(inspection.Supports(libeth.InterfaceERC721)).Should(BeFalse())
+		})
+	})
+
+	Context("when the address is a proxy of an ERC721 contract", func() {
+		It("should report the interfaces and the implementation", func() {
+			implementation := common.HexToAddress("0x1a2b")
+			inspection := inspect(func(eth *FakeEth) {
+				eth.storage[contract.Hex()+common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc").Hex()] = common.BytesToHash(implementation.Bytes())
+				eth.respond(contract, selector("supportsInterface(bytes4)"), word(0))
+				eth.respondTo(contract, supportsInterface(libeth.InterfaceERC165), word(1))
+				eth.respondTo(contract, supportsInterface(libeth.InterfaceERC721), word(1))
+				eth.respondTo(contract, supportsInterface(libeth.InterfaceERC721Metadata), word(1))
+				eth.respond(contract, selector("balanceOf(address)"), word(0))
+			})
+			Expect(inspection.ERC165).Should(BeTrue())
+			Expect(inspection.Supports(libeth.InterfaceERC721Metadata)).Should(BeTrue())
+			Expect(inspection.Supports(libeth.InterfaceERC1155)).Should(BeFalse())
+			Expect(inspection.ERC20).Should(BeFalse())
+			Expect(inspection.Standards()).Should(Equal([]libeth.TokenStandard{libeth.StandardERC721}))
+			Expect(*inspection.Implementation).Should(Equal(implementation))
+		})
+	})
+
+	Context("when validating the target of a constructor", func() {
+		It("should reject invalid addresses, accounts and other standards", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			erc20(eth)
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err = client.NewERC20View("0xnot-an-address", libeth.Validate(ctx))
+			Expect(err).Should(Equal(libeth.ErrInvalidAddress))
+			_, err = client.NewERC20View(common.HexToAddress("0xa11ce").Hex(), libeth.Validate(ctx))
+			Expect(err).Should(Equal(libeth.ErrNotContract))
+			_, err = client.NewNFT721View(contract.Hex(), libeth.Validate(ctx))
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.StandardMismatchError{}))
+
+			token, err := client.NewERC20View(contract.Hex(), libeth.Validate(ctx))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(token.Address()).Should(Equal(contract))
+
+			// Without validation, constructors bind whatever they are given
+			_, err = client.NewERC20View("0xnot-an-address")
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,165 @@
+package libeth
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrKeyNotFound indicates that the keystore directory does not contain a key
+// for the given address.
+var ErrKeyNotFound = errors.New("key not found in keystore")
+
+// Keystore manages a directory of keys encrypted using the Web3 Secret Storage
+// format, which is the format used by geth, Clef and most wallets.
+type Keystore struct {
+	ks *keystore.KeyStore
+}
+
+// NewKeystore returns a Keystore for the given directory, which is created if
+// it does not exist. The light scrypt parameters make encryption considerably
+// faster at the cost of security and should only be used for testing.
+func NewKeystore(dir string, lightKDF bool) *Keystore {
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if lightKDF {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	return &Keystore{
+		ks: keystore.NewKeyStore(dir, scryptN, scryptP),
+	}
+}
+
+// List returns the addresses of all keys in the directory.
+func (store *Keystore) List() []common.Address {
+	accs := store.ks.Accounts()
+	addresses := make([]common.Address, len(accs))
+	for i, acc := range accs {
+		addresses[i] = acc.Address
+	}
+	return addresses
+}
+
+// Has returns true if the directory contains a key for the given address.
+func (store *Keystore) Has(address common.Address) bool {
+	return store.ks.HasAddress(address)
+}
+
+// Create generates a new key, encrypts it with the passphrase and stores it in
+// the directory.
+func (store *Keystore) Create(passphrase string) (common.Address, error) {
+	acc, err := store.ks.NewAccount(passphrase)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return acc.Address, nil
+}
+
+// Import stores an encrypted JSON key in the directory, re-encrypting it with
+// the new passphrase.
+func (store *Keystore) Import(keyJSON []byte, passphrase, newPassphrase string) (common.Address, error) {
+	acc, err := store.ks.Import(keyJSON, passphrase, newPassphrase)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return acc.Address, nil
+}
+
+// ImportHex encrypts a hex encoded private key with the passphrase and stores
+// it in the directory. It is intended for migrating plaintext keys.
+func (store *Keystore) ImportHex(privateKey, passphrase string) (common.Address, error) {
+	key, err := crypto.HexToECDSA(privateKey)
+	if err != nil {
+		return common.Address{}, err
+	}
+	defer zeroKey(key)
+
+	acc, err := store.ks.ImportECDSA(key, passphrase)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return acc.Address, nil
+}
+
+// Export returns the encrypted JSON key for the given address, re-encrypted
+// with the new passphrase.
+func (store *Keystore) Export(address common.Address, passphrase, newPassphrase string) ([]byte, error) {
+	acc, err := store.find(address)
+	if err != nil {
+		return nil, err
+	}
+	return store.ks.Export(acc, passphrase, newPassphrase)
+}
+
+// Update re-encrypts the key for the given address with the new passphrase.
+func (store *Keystore) Update(address common.Address, passphrase, newPassphrase string) error {
+	acc, err := store.find(address)
+	if err != nil {
+		return err
+	}
+	return store.ks.Update(acc, passphrase, newPassphrase)
+}
+
+// Delete removes the key for the given address from the directory.
+func (store *Keystore) Delete(address common.Address, passphrase string) error {
+	acc, err := store.find(address)
+	if err != nil {
+		return err
+	}
+	return store.ks.Delete(acc, passphrase)
+}
+
+// Signer returns a KeystoreSigner for the key of the given address.
+func (store *Keystore) Signer(address common.Address, passphrase string) (KeystoreSigner, error) {
+	acc, err := store.find(address)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeystoreSigner(acc.URL.Path, passphrase)
+}
+
+// Account returns an Account for the key of the given address which is
+// connected to the client.
+func (store *Keystore) Account(client Client, address common.Address, passphrase string) (Account, error) {
+	signer, err := store.Signer(address, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountWithSigner(client, signer)
+}
+
+// Accounts returns an Account for every key in the directory which is
+// connected to the client. All keys must be encrypted with the same
+// passphrase.
+func (store *Keystore) Accounts(client Client, passphrase string) ([]Account, error) {
+	addresses := store.List()
+	accs := make([]Account, 0, len(addresses))
+	for _, address := range addresses {
+		account, err := store.Account(client, address, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		accs = append(accs, account)
+	}
+	return accs, nil
+}
+
+func (store *Keystore) find(address common.Address) (accounts.Account, error) {
+	acc, err := store.ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return accounts.Account{}, ErrKeyNotFound
+	}
+	return acc, nil
+}
+
+// LoadAccount returns an Account for an encrypted JSON key file which is
+// connected to the client.
+func LoadAccount(client Client, path, passphrase string) (Account, error) {
+	signer, err := NewKeystoreSigner(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewAccountWithSigner(client, signer)
+}
@@ -0,0 +1,79 @@
+package libeth_test
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("keystore", func() {
+
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "libeth-keystore")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("when managing keys in a directory", func() {
+		It("should create, list, re-encrypt and delete keys", func() {
+			store := libeth.NewKeystore(dir, true)
+			address, err := store.Create("passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(store.List()).Should(Equal([]common.Address{address}))
+			Expect(store.Has(address)).Should(BeTrue())
+
+			Expect(store.Update(address, "passphrase", "new passphrase")).Should(Succeed())
+			_, err = store.Signer(address, "passphrase")
+			Expect(err).Should(HaveOccurred())
+			signer, err := store.Signer(address, "new passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(signer.Address()).Should(Equal(address))
+
+			Expect(store.Delete(address, "new passphrase")).Should(Succeed())
+			Expect(store.List()).Should(BeEmpty())
+			_, err = store.Signer(address, "new passphrase")
+			Expect(err).Should(Equal(libeth.ErrKeyNotFound))
+		})
+
+		It("should import plaintext keys and export them encrypted", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			expected := crypto.PubkeyToAddress(key.PublicKey)
+
+			store := libeth.NewKeystore(dir, true)
+			address, err := store.ImportHex(hex.EncodeToString(crypto.FromECDSA(key)), "passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(address).Should(Equal(expected))
+
+			keyJSON, err := store.Export(address, "passphrase", "export passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			signer, err := libeth.NewKeystoreSignerFromJSON(keyJSON, "export passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(signer.Address()).Should(Equal(expected))
+
+			_, err = store.Export(common.HexToAddress("0x1"), "passphrase", "passphrase")
+			Expect(err).Should(Equal(libeth.ErrKeyNotFound))
+
+			otherDir, err := ioutil.TempDir("", "libeth-keystore")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(otherDir)
+			other := libeth.NewKeystore(otherDir, true)
+			imported, err := other.Import(keyJSON, "export passphrase", "passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(imported).Should(Equal(expected))
+		})
+	})
+})
@@ -0,0 +1,118 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidSignature indicates that a signature is malformed or cannot be
+// recovered.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// PersonalMessageHash returns the EIP-191 hash of a personal message, which is
+// the hash that wallets sign for eth_sign and personal_sign.
+func PersonalMessageHash(msg []byte) common.Hash {
+	return crypto.Keccak256Hash([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(msg))), msg)
+}
+
+// SignPersonalMessage signs the EIP-191 hash of the message. The signature has
+// a V of 27 or 28, as expected by wallets and by ecrecover.
+func SignPersonalMessage(ctx context.Context, signer Signer, msg []byte) ([]byte, error) {
+	hash := PersonalMessageHash(msg)
+	sig, err := signer.SignHash(ctx, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	return SignatureWithV27(sig)
+}
+
+// SignTypedData signs the EIP-712 digest of the typed data. The signature has
+// a V of 27 or 28, as expected by wallets and by ecrecover.
+func SignTypedData(ctx context.Context, signer Signer, typedData TypedData) ([]byte, error) {
+	sig, err := signer.SignTypedData(ctx, typedData)
+	if err != nil {
+		return nil, err
+	}
+	return SignatureWithV27(sig)
+}
+
+// Recover returns the address that signed the hash. V can be either 0/1 or
+// 27/28.
+func Recover(hash, sig []byte) (common.Address, error) {
+	sig, err := SignatureWithV0(sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, ErrInvalidSignature
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// Verify returns true if the hash was signed by the given address.
+func Verify(address common.Address, hash, sig []byte) bool {
+	signer, err := Recover(hash, sig)
+	return err == nil && signer == address
+}
+
+// RecoverPersonalMessage returns the address that signed the personal
+// message.
+func RecoverPersonalMessage(msg, sig []byte) (common.Address, error) {
+	hash := PersonalMessageHash(msg)
+	return Recover(hash[:], sig)
+}
+
+// VerifyPersonalMessage returns true if the personal message was signed by the
+// given address.
+func VerifyPersonalMessage(address common.Address, msg, sig []byte) bool {
+	hash := PersonalMessageHash(msg)
+	return Verify(address, hash[:], sig)
+}
+
+// RecoverTypedData returns the address that signed the typed data.
+func RecoverTypedData(typedData TypedData, sig []byte) (common.Address, error) {
+	hash, err := typedData.SigningHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	return Recover(hash[:], sig)
+}
+
+// VerifyTypedData returns true if the typed data was signed by the given
+// address.
+func VerifyTypedData(address common.Address, typedData TypedData, sig []byte) bool {
+	hash, err := typedData.SigningHash()
+	return err == nil && Verify(address, hash[:], sig)
+}
+
+// SignatureWithV27 returns a copy of the signature with V set to 27 or 28,
+// which is the format used by wallets and the ecrecover precompile.
+func SignatureWithV27(sig []byte) ([]byte, error) {
+	sig, err := SignatureWithV0(sig)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// SignatureWithV0 returns a copy of the signature with V set to 0 or 1, which
+// is the format used by go-ethereum.
+func SignatureWithV0(sig []byte) ([]byte, error) {
+	if len(sig) != 65 {
+		return nil, ErrInvalidSignature
+	}
+	sig = common.CopyBytes(sig)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	if sig[64] > 1 {
+		return nil, ErrInvalidSignature
+	}
+	return sig, nil
+}
@@ -0,0 +1,74 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("messages", func() {
+
+	Context("when signing personal messages", func() {
+		It("should produce signatures that can be verified", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := libeth.NewPrivateKeySigner(key)
+
+			msg := []byte("Message")
+			sig, err := libeth.SignPersonalMessage(context.Background(), signer, msg)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sig[64]).Should(BeNumerically(">=", 27))
+
+			address, err := libeth.RecoverPersonalMessage(msg, sig)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(address).Should(Equal(signer.Address()))
+			Expect(libeth.VerifyPersonalMessage(signer.Address(), msg, sig)).Should(BeTrue())
+			Expect(libeth.VerifyPersonalMessage(signer.Address(), []byte("Other message"), sig)).Should(BeFalse())
+
+			// Signatures with V of 0 or 1 are accepted as well
+			sig, err = libeth.SignatureWithV0(sig)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(libeth.VerifyPersonalMessage(signer.Address(), msg, sig)).Should(BeTrue())
+		})
+	})
+
+	Context("when signing typed data", func() {
+		It("should match the EIP-712 specification", func() {
+			var typedData libeth.TypedData
+			Expect(json.Unmarshal([]byte(mailTypedData), &typedData)).Should(Succeed())
+
+			key, err := crypto.ToECDSA(crypto.Keccak256([]byte("cow")))
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := libeth.NewPrivateKeySigner(key)
+			Expect(signer.Address()).Should(Equal(common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826")))
+
+			sig, err := libeth.SignTypedData(context.Background(), signer, typedData)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hex.EncodeToString(sig[:32])).Should(Equal("4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d"))
+			Expect(hex.EncodeToString(sig[32:64])).Should(Equal("07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562"))
+			Expect(sig[64]).Should(Equal(byte(28)))
+
+			Expect(libeth.VerifyTypedData(signer.Address(), typedData, sig)).Should(BeTrue())
+			typedData.Message["contents"] = "Hello, Alice!"
+			Expect(libeth.VerifyTypedData(signer.Address(), typedData, sig)).Should(BeFalse())
+		})
+	})
+
+	Context("when normalising signatures", func() {
+		It("should reject malformed signatures", func() {
+			_, err := libeth.SignatureWithV27(make([]byte, 64))
+			Expect(err).Should(Equal(libeth.ErrInvalidSignature))
+			sig := make([]byte, 65)
+			sig[64] = 29
+			_, err = libeth.SignatureWithV0(sig)
+			Expect(err).Should(Equal(libeth.ErrInvalidSignature))
+		})
+	})
+})
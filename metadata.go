@@ -0,0 +1,107 @@
+package libeth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsupportedURI indicates that the metadata URI of a token cannot be
+// fetched.
+var ErrUnsupportedURI = errors.New("unsupported metadata uri")
+
+// IPFSGateway and ArweaveGateway are the HTTP gateways used to resolve ipfs://
+// and ar:// metadata URIs.
+var (
+	IPFSGateway    = "https://ipfs.io/ipfs/"
+	ArweaveGateway = "https://arweave.net/"
+)
+
+// TokenMetadata is the JSON metadata of an ERC721 or ERC1155 token, as
+// described by the metadata extensions of both standards. Raw holds the whole
+// document, for fields that are not part of the standards.
+type TokenMetadata struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Image       string          `json:"image"`
+	Attributes  json.RawMessage `json:"attributes,omitempty"`
+	Raw         json.RawMessage `json:"-"`
+}
+
+// ResolveTokenURI returns the URI at which the metadata of the token can be
+// fetched. The {id} placeholder of ERC1155 is replaced by the ID as 64 hex
+// digits, and ipfs:// and ar:// URIs are rewritten to their gateways.
+func ResolveTokenURI(uri string, id *big.Int) string {
+	uri = strings.TrimSpace(uri)
+	if id != nil {
+		uri = strings.Replace(uri, "{id}", fmt.Sprintf("%064x", id), -1)
+	}
+	switch {
+	case strings.HasPrefix(uri, "ipfs://ipfs/"):
+		return IPFSGateway + strings.TrimPrefix(uri, "ipfs://ipfs/")
+	case strings.HasPrefix(uri, "ipfs://"):
+		return IPFSGateway + strings.TrimPrefix(uri, "ipfs://")
+	case strings.HasPrefix(uri, "ar://"):
+		return ArweaveGateway + strings.TrimPrefix(uri, "ar://")
+	}
+	return uri
+}
+
+// fetchMetadata reads the metadata at the resolved URI, which is either an
+// HTTP(S) URL or a JSON data URI.
+func fetchMetadata(ctx context.Context, uri string) (TokenMetadata, error) {
+	var data []byte
+	switch {
+	case strings.HasPrefix(uri, "data:"):
+		comma := strings.Index(uri, ",")
+		if comma < 0 {
+			return TokenMetadata{}, ErrUnsupportedURI
+		}
+		header, payload := uri[len("data:"):comma], uri[comma+1:]
+		if strings.HasSuffix(header, ";base64") {
+			decoded, err := base64.StdEncoding.DecodeString(payload)
+			if err != nil {
+				return TokenMetadata{}, err
+			}
+			data = decoded
+		} else {
+			unescaped, err := url.PathUnescape(payload)
+			if err != nil {
+				return TokenMetadata{}, err
+			}
+			data = []byte(unescaped)
+		}
+	case strings.HasPrefix(uri, "http://"), strings.HasPrefix(uri, "https://"):
+		req, err := http.NewRequest(http.MethodGet, uri, nil)
+		if err != nil {
+			return TokenMetadata{}, err
+		}
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		if err != nil {
+			return TokenMetadata{}, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return TokenMetadata{}, fmt.Errorf("cannot fetch metadata from %v: %v", uri, resp.Status)
+		}
+		if data, err = ioutil.ReadAll(resp.Body); err != nil {
+			return TokenMetadata{}, err
+		}
+	default:
+		return TokenMetadata{}, ErrUnsupportedURI
+	}
+
+	metadata := TokenMetadata{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return TokenMetadata{}, err
+	}
+	metadata.Raw = json.RawMessage(data)
+	return metadata, nil
+}
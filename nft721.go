@@ -0,0 +1,392 @@
+package libeth
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+type nft721 struct {
+	client   *Client
+	account  Account
+	address  common.Address
+	bindings *bindings.ERC721
+}
+
+type NFT721 interface {
+	NFT721View
+
+	// SafeTransferFrom transfers the token from its owner, which is either
+	// the account or has approved the account, to the recipient. Contracts
+	// that do not accept ERC721 tokens reject the transfer.
+	SafeTransferFrom(ctx context.Context, from, to common.Address, tokenID *big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
+	Approve(ctx context.Context, to common.Address, tokenID *big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
+	SetApprovalForAll(ctx context.Context, operator common.Address, approved bool, speed TxExecutionSpeed) (*types.Transaction, error)
+	SafeTransferFromWithOptions(ctx context.Context, from, to common.Address, tokenID *big.Int, data []byte, options TransactOptions) (*types.Transaction, error)
+	ApproveWithOptions(ctx context.Context, to common.Address, tokenID *big.Int, options TransactOptions) (*types.Transaction, error)
+	SetApprovalForAllWithOptions(ctx context.Context, operator common.Address, approved bool, options TransactOptions) (*types.Transaction, error)
+}
+
+type NFT721View interface {
+	Address() common.Address
+	Name(ctx context.Context) (string, error)
+	Symbol(ctx context.Context) (string, error)
+	OwnerOf(ctx context.Context, tokenID *big.Int) (common.Address, error)
+	BalanceOf(ctx context.Context, owner common.Address) (*big.Int, error)
+	GetApproved(ctx context.Context, tokenID *big.Int) (common.Address, error)
+	IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error)
+
+	// TokenURI returns the metadata URI of the token, resolved by
+	// ResolveTokenURI, and Metadata fetches it.
+	TokenURI(ctx context.Context, tokenID *big.Int) (string, error)
+	Metadata(ctx context.Context, tokenID *big.Int) (TokenMetadata, error)
+
+	// Transfers, Approvals and ApprovalsForAll return the decoded events of
+	// the contract within the block range, with the timestamps of their
+	// blocks. Large ranges are queried in pages.
+	Transfers(ctx context.Context, from, to []common.Address, tokenIDs []*big.Int, blockRange BlockRange) ([]NFT721TransferEvent, error)
+	Approvals(ctx context.Context, owner, approved []common.Address, tokenIDs []*big.Int, blockRange BlockRange) ([]NFT721ApprovalEvent, error)
+	ApprovalsForAll(ctx context.Context, owner, operator []common.Address, blockRange BlockRange) ([]ApprovalForAllEvent, error)
+}
+
+// NFT721TransferEvent is a decoded ERC721 Transfer event. Mints are transfers
+// from the zero address, and burns are transfers to it.
+type NFT721TransferEvent struct {
+	EventMeta
+	From    common.Address
+	To      common.Address
+	TokenID *big.Int
+}
+
+// NFT721ApprovalEvent is a decoded ERC721 Approval event.
+type NFT721ApprovalEvent struct {
+	EventMeta
+	Owner    common.Address
+	Approved common.Address
+	TokenID  *big.Int
+}
+
+// ApprovalForAllEvent is a decoded ApprovalForAll event, which ERC721 and
+// ERC1155 share.
+type ApprovalForAllEvent struct {
+	EventMeta
+	Owner    common.Address
+	Operator common.Address
+	Approved bool
+}
+
+func (account *account) NewNFT721(addressOrAlias string, options ...ContractOption) (NFT721, error) {
+	address, err := account.client.resolveContract(account.addressBook, addressOrAlias, StandardERC721, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC721(address, bind.ContractBackend(account.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	client := account.Client()
+	return &nft721{
+		client:   &client,
+		account:  account,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+func (client *Client) NewNFT721View(addressOrAlias string, options ...ContractOption) (NFT721View, error) {
+	address, err := client.resolveContract(client.addrBook, addressOrAlias, StandardERC721, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC721(address, bind.ContractBackend(client.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	return &nft721{
+		client:   client,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+func (nft *nft721) Address() common.Address {
+	return nft.address
+}
+
+func (nft *nft721) Name(ctx context.Context) (string, error) {
+	var name string
+	return name, nft.client.Get(ctx, func() (err error) {
+		name, err = nft.bindings.Name(&bind.CallOpts{Context: ctx})
+		return err
+	})
+}
+
+func (nft *nft721) Symbol(ctx context.Context) (string, error) {
+	var symbol string
+	return symbol, nft.client.Get(ctx, func() (err error) {
+		symbol, err = nft.bindings.Symbol(&bind.CallOpts{Context: ctx})
+		return err
+	})
+}
+
+func (nft *nft721) OwnerOf(ctx context.Context, tokenID *big.Int) (common.Address, error) {
+	var owner common.Address
+	return owner, nft.client.Get(ctx, func() (err error) {
+		owner, err = nft.bindings.OwnerOf(&bind.CallOpts{Context: ctx}, tokenID)
+		return err
+	})
+}
+
+func (nft *nft721) BalanceOf(ctx context.Context, owner common.Address) (*big.Int, error) {
+	var balance *big.Int
+	return balance, nft.client.Get(ctx, func() (err error) {
+		balance, err = nft.bindings.BalanceOf(&bind.CallOpts{Context: ctx}, owner)
+		return err
+	})
+}
+
+func (nft *nft721) GetApproved(ctx context.Context, tokenID *big.Int) (common.Address, error) {
+	var approved common.Address
+	return approved, nft.client.Get(ctx, func() (err error) {
+		approved, err = nft.bindings.GetApproved(&bind.CallOpts{Context: ctx}, tokenID)
+		return err
+	})
+}
+
+func (nft *nft721) IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error) {
+	var approved bool
+	return approved, nft.client.Get(ctx, func() (err error) {
+		approved, err = nft.bindings.IsApprovedForAll(&bind.CallOpts{Context: ctx}, owner, operator)
+		return err
+	})
+}
+
+func (nft *nft721) TokenURI(ctx context.Context, tokenID *big.Int) (string, error) {
+	var uri string
+	err := nft.client.Get(ctx, func() (err error) {
+		uri, err = nft.bindings.TokenURI(&bind.CallOpts{Context: ctx}, tokenID)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return ResolveTokenURI(uri, tokenID), nil
+}
+
+func (nft *nft721) Metadata(ctx context.Context, tokenID *big.Int) (TokenMetadata, error) {
+	uri, err := nft.TokenURI(ctx, tokenID)
+	if err != nil {
+		return TokenMetadata{}, err
+	}
+	return fetchMetadata(ctx, uri)
+}
+
+func (nft *nft721) SafeTransferFrom(ctx context.Context, from, to common.Address, tokenID *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return nft.SafeTransferFromWithOptions(ctx, from, to, tokenID, nil, DefaultTransactOptions(speed, 1))
+}
+
+// SafeTransferFromWithOptions transfers the token with the data, as configured
+// by the options. The transfer is only sent while the token is owned by the
+// sender, and unless the options have their own post-condition, it is only
+// complete once the token is owned by the recipient.
+func (nft *nft721) SafeTransferFromWithOptions(ctx context.Context, from, to common.Address, tokenID *big.Int, data []byte, options TransactOptions) (*types.Transaction, error) {
+	pre := NFTOwnedBy(nft.address, tokenID, from)
+	if options.PreCondition != nil {
+		pre = All(options.PreCondition, pre)
+	}
+	options.PreCondition = pre
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		options.PostCondition = NFTOwnedBy(nft.address, tokenID, to)
+	}
+	options.Idempotent = true
+
+	return nft.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return nft.bindings.SafeTransferFrom(tops, from, to, tokenID, data)
+		},
+		options,
+	)
+}
+
+func (nft *nft721) Approve(ctx context.Context, to common.Address, tokenID *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return nft.ApproveWithOptions(ctx, to, tokenID, DefaultTransactOptions(speed, 1))
+}
+
+// ApproveWithOptions approves the address to transfer the token, as
+// configured by the options. The zero address clears the approval. Unless the
+// options have their own post-condition, the approval is only complete once
+// it has been set.
+func (nft *nft721) ApproveWithOptions(ctx context.Context, to common.Address, tokenID *big.Int, options TransactOptions) (*types.Transaction, error) {
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		options.PostCondition = nft.condition(func(opts *bind.CallOpts) (bool, error) {
+			approved, err := nft.bindings.GetApproved(opts, tokenID)
+			return approved == to, err
+		})
+	}
+	options.Idempotent = true
+
+	return nft.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return nft.bindings.Approve(tops, to, tokenID)
+		},
+		options,
+	)
+}
+
+func (nft *nft721) SetApprovalForAll(ctx context.Context, operator common.Address, approved bool, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return nft.SetApprovalForAllWithOptions(ctx, operator, approved, DefaultTransactOptions(speed, 1))
+}
+
+// SetApprovalForAllWithOptions approves or revokes the operator for all
+// tokens of the account, as configured by the options. Unless the options
+// have their own post-condition, it is only complete once it has been set.
+func (nft *nft721) SetApprovalForAllWithOptions(ctx context.Context, operator common.Address, approved bool, options TransactOptions) (*types.Transaction, error) {
+	owner := nft.account.Address()
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		options.PostCondition = nft.condition(func(opts *bind.CallOpts) (bool, error) {
+			isApproved, err := nft.bindings.IsApprovedForAll(opts, owner, operator)
+			return isApproved == approved, err
+		})
+	}
+	options.Idempotent = true
+
+	return nft.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return nft.bindings.SetApprovalForAll(tops, operator, approved)
+		},
+		options,
+	)
+}
+
+// condition returns a condition that calls the contract at the block of the
+// check, and depends on the logs of the contract.
+func (nft *nft721) condition(check func(opts *bind.CallOpts) (bool, error)) Condition {
+	return DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		return check(&bind.CallOpts{BlockNumber: block, Context: ctx})
+	}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{nft.address}}}})
+}
+
+// Transfers returns the transfers of the tokens between the addresses within
+// the block range, oldest first. Empty addresses or token IDs match anything.
+func (nft *nft721) Transfers(ctx context.Context, from, to []common.Address, tokenIDs []*big.Int, blockRange BlockRange) ([]NFT721TransferEvent, error) {
+	events := []NFT721TransferEvent{}
+	err := nft.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := nft.bindings.FilterTransfer(opts, from, to, tokenIDs)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []NFT721TransferEvent{}
+		for iter.Next() {
+			page = append(page, NFT721TransferEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				From:      iter.Event.From,
+				To:        iter.Event.To,
+				TokenID:   iter.Event.TokenId,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(nft.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Approvals returns the approvals of the tokens within the block range,
+// oldest first. Empty addresses or token IDs match anything.
+func (nft *nft721) Approvals(ctx context.Context, owner, approved []common.Address, tokenIDs []*big.Int, blockRange BlockRange) ([]NFT721ApprovalEvent, error) {
+	events := []NFT721ApprovalEvent{}
+	err := nft.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := nft.bindings.FilterApproval(opts, owner, approved, tokenIDs)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []NFT721ApprovalEvent{}
+		for iter.Next() {
+			page = append(page, NFT721ApprovalEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				Owner:     iter.Event.Owner,
+				Approved:  iter.Event.Approved,
+				TokenID:   iter.Event.TokenId,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(nft.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// ApprovalsForAll returns the approvals and revocations of operators within
+// the block range, oldest first. Empty addresses match any address.
+func (nft *nft721) ApprovalsForAll(ctx context.Context, owner, operator []common.Address, blockRange BlockRange) ([]ApprovalForAllEvent, error) {
+	events := []ApprovalForAllEvent{}
+	err := nft.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := nft.bindings.FilterApprovalForAll(opts, owner, operator)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []ApprovalForAllEvent{}
+		for iter.Next() {
+			page = append(page, ApprovalForAllEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				Owner:     iter.Event.Owner,
+				Operator:  iter.Event.Operator,
+				Approved:  iter.Event.Approved,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(nft.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
@@ -0,0 +1,179 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+var _ = Describe("nfts", func() {
+
+	collection := common.HexToAddress("0xc011")
+	alice, bob := common.HexToAddress("0xa11ce"), common.HexToAddress("0xb0b")
+
+	selector := func(signature string) []byte {
+		return crypto.Keccak256([]byte(signature))[:4]
+	}
+	word := func(value *big.Int) []byte {
+		return common.LeftPadBytes(value.Bytes(), 32)
+	}
+
+	Context("when resolving metadata uris", func() {
+		It("should substitute ids and rewrite gateways", func() {
+			Expect(libeth.ResolveTokenURI("ipfs://Qm/1.json", big.NewInt(1))).Should(Equal(libeth.IPFSGateway + "Qm/1.json"))
+			Expect(libeth.ResolveTokenURI("ipfs://ipfs/Qm", nil)).Should(Equal(libeth.IPFSGateway + "Qm"))
+			Expect(libeth.ResolveTokenURI("ar://tx", nil)).Should(Equal(libeth.ArweaveGateway + "tx"))
+			Expect(libeth.ResolveTokenURI("https://token.io/{id}.json", big.NewInt(314592))).Should(Equal("https://token.io/000000000000000000000000000000000000000000000000000000000004cce0.json"))
+		})
+
+		It("should read ERC721 metadata from a data uri", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			metadata := `{"name":"Vault #7","image":"ipfs://Qm","attributes":[{"trait_type":"tier","value":1}]}`
+			uri := "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(metadata))
+			eth.respond(collection, selector("tokenURI(uint256)"), abiString(uri))
+			eth.respond(collection, selector("ownerOf(uint256)"), common.LeftPadBytes(alice.Bytes(), 32))
+
+			nft, err := client.NewNFT721View(collection.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			owner, err := nft.OwnerOf(ctx, big.NewInt(7))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(owner).Should(Equal(alice))
+			meta, err := nft.Metadata(ctx, big.NewInt(7))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(meta.Name).Should(Equal("Vault #7"))
+			Expect(meta.Image).Should(Equal("ipfs://Qm"))
+			Expect(string(meta.Attributes)).Should(ContainSubstring("tier"))
+			Expect(string(meta.Raw)).Should(Equal(metadata))
+		})
+	})
+
+	Context("when transferring an ERC721 token", func() {
+		transfer := func(owner func(account libeth.Account) common.Address) (libeth.DryRunAccount, error) {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.respond(collection, selector("ownerOf(uint256)"), common.LeftPadBytes(owner(account).Bytes(), 32))
+
+			nft, err := account.NewNFT721(collection.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_, err = nft.SafeTransferFromWithOptions(ctx, account.Address(), bob, big.NewInt(7), nil, libeth.DefaultTransactOptions(libeth.Fast, 1))
+			return account, err
+		}
+
+		It("should send a safe transfer when the account owns the token", func() {
+			account, err := transfer(func(account libeth.Account) common.Address { return account.Address() })
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(account.Log()).Should(HaveLen(1))
+			Expect(account.Log()[0].Tx.Data()[:4]).Should(Equal(selector("safeTransferFrom(address,address,uint256,bytes)")))
+		})
+
+		It("should not send a transfer when the account does not own the token", func() {
+			account, err := transfer(func(libeth.Account) common.Address { return alice })
+			Expect(err).Should(Equal(libeth.ErrPreConditionCheckFailed))
+			Expect(account.Log()).Should(BeEmpty())
+		})
+	})
+
+	Context("when transferring an ERC1155 token", func() {
+		It("should complete once the transfer event is emitted, even if the balance does not increase", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Other transfers keep the balance of the recipient the same
+			eth.respond(collection, selector("balanceOf(address,uint256)"), word(big.NewInt(5)))
+			eth.mineWithLogs(ctx, 0, &types.Log{
+				Address: collection,
+				Topics: []common.Hash{
+					crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)")),
+					common.BytesToHash(account.Address().Bytes()),
+					common.BytesToHash(account.Address().Bytes()),
+					common.BytesToHash(bob.Bytes()),
+				},
+				Data: append(word(big.NewInt(7)), word(big.NewInt(2))...),
+			})
+
+			token, err := account.NewToken1155(collection.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.PostConditionTimeout = 2 * time.Second
+			_, err = token.SafeTransferFromWithOptions(ctx, account.Address(), bob, big.NewInt(7), big.NewInt(2), nil, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(eth.minedTxs()).Should(HaveLen(1))
+		})
+	})
+
+	Context("when reading ERC1155 history", func() {
+		It("should merge single and batch transfers in log order", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			parsed, err := abi.JSON(strings.NewReader(bindings.ERC1155ABI))
+			Expect(err).ShouldNot(HaveOccurred())
+			topics := func(event string) []common.Hash {
+				return []common.Hash{parsed.Events[event].Id(), common.BytesToHash(alice.Bytes()), common.BytesToHash(alice.Bytes()), common.BytesToHash(bob.Bytes())}
+			}
+			batch, err := parsed.Events["TransferBatch"].Inputs.NonIndexed().Pack([]*big.Int{big.NewInt(1), big.NewInt(2)}, []*big.Int{big.NewInt(10), big.NewInt(20)})
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.logs = []types.Log{
+				{Address: collection, Topics: topics("TransferSingle"), Data: append(word(big.NewInt(3)), word(big.NewInt(30))...), BlockNumber: 5, Index: 2},
+				{Address: collection, Topics: topics("TransferBatch"), Data: batch, BlockNumber: 5, Index: 1},
+			}
+			eth.head = 10
+			eth.respond(collection, selector("uri(uint256)"), abiString("ipfs://Qm/{id}.json"))
+
+			token, err := client.NewToken1155View(collection.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			transfers, err := token.Transfers(ctx, nil, []common.Address{alice}, nil, libeth.BlockRange{})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(transfers).Should(HaveLen(3))
+			Expect(transfers[0].ID).Should(Equal(big.NewInt(1)))
+			Expect(transfers[1].Value).Should(Equal(big.NewInt(20)))
+			Expect(transfers[2].ID).Should(Equal(big.NewInt(3)))
+			Expect(transfers[2].To).Should(Equal(bob))
+			Expect(transfers[2].Timestamp).Should(Equal(time.Unix(50, 0)))
+
+			uri, err := token.URI(ctx, big.NewInt(1))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(uri).Should(Equal(libeth.IPFSGateway + "Qm/0000000000000000000000000000000000000000000000000000000000000001.json"))
+		})
+	})
+})
@@ -0,0 +1,442 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/renproject/libeth-go"
+)
+
+// FakeEth is a minimal Ethereum node that answers calls to contracts with
+// canned responses, keyed by contract address and function selector.
+type FakeEth struct {
+	mu        *sync.Mutex
+	responses map[string]hexutil.Bytes
+
+	// Logs are returned by GetLogs, which rejects queries of more than
+	// maxLogRange blocks if it is set. Blocks have a timestamp of ten times
+	// their number.
+	logs        []types.Log
+	head        uint64
+	maxLogRange uint64
+	logQueries  int
+
+	// Queries of the latest block are counted by GetBlockByNumber.
+	headQueries int
+
+	// Storage is read by GetStorageAt, keyed by contract address and slot.
+	// Balances are read by GetBalance, and are zero unless they are set.
+	storage  map[string]common.Hash
+	balances map[common.Address]*big.Int
+
+	// Transactions that are sent wait in the mempool, by nonce, until they are
+	// mined. All transactions are assumed to be sent by the same account,
+	// whose nonce is the number of mined transactions. Mined transactions
+	// emit the receipt logs set for their hash.
+	mempool     map[uint64]*types.Transaction
+	mined       []*types.Transaction
+	minedBlocks map[common.Hash]uint64
+	receiptLogs map[common.Hash][]*types.Log
+}
+
+type FakeCallArgs struct {
+	To   *common.Address `json:"to"`
+	Data hexutil.Bytes   `json:"data"`
+}
+
+type FakeFilterArgs struct {
+	FromBlock *hexutil.Big     `json:"fromBlock"`
+	ToBlock   *hexutil.Big     `json:"toBlock"`
+	Addresses []common.Address `json:"address"`
+	Topics    [][]common.Hash  `json:"topics"`
+}
+
+func newFakeNode() (*httptest.Server, *FakeEth) {
+	eth := &FakeEth{
+		mu:        new(sync.Mutex),
+		responses: map[string]hexutil.Bytes{},
+		storage:   map[string]common.Hash{},
+		balances:  map[common.Address]*big.Int{},
+
+		mempool:     map[uint64]*types.Transaction{},
+		minedBlocks: map[common.Hash]uint64{},
+		receiptLogs: map[common.Hash][]*types.Log{},
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", eth); err != nil {
+		panic(err)
+	}
+	if err := server.RegisterName("net", &FakeNet{}); err != nil {
+		panic(err)
+	}
+	// Raw requests to the node, such as those of CurrentBlockNumber, do not set
+	// a content type
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set("Content-Type", "application/json")
+		server.ServeHTTP(w, r)
+	})), eth
+}
+
+func (eth *FakeEth) respond(contract common.Address, selector []byte, response []byte) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	eth.responses[contract.Hex()+hex.EncodeToString(selector)] = response
+}
+
+// respondTo sets the response to calls with exactly the given data, which
+// takes precedence over the response to the selector.
+func (eth *FakeEth) respondTo(contract common.Address, data []byte, response []byte) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	eth.responses[contract.Hex()+hex.EncodeToString(data)] = response
+}
+
+// respondAt sets the response to calls with exactly the given data at the
+// block, which takes precedence over the other responses.
+func (eth *FakeEth) respondAt(contract common.Address, data []byte, block uint64, response []byte) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	eth.responses[contract.Hex()+hex.EncodeToString(data)+"@"+hexutil.EncodeUint64(block)] = response
+}
+
+func (eth *FakeEth) Call(args FakeCallArgs, block string) (hexutil.Bytes, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	if args.To == nil || len(args.Data) < 4 {
+		return nil, errors.New("invalid call")
+	}
+	response, ok := eth.responses[args.To.Hex()+hex.EncodeToString(args.Data)+"@"+block]
+	if !ok {
+		response, ok = eth.responses[args.To.Hex()+hex.EncodeToString(args.Data)]
+	}
+	if !ok {
+		response, ok = eth.responses[args.To.Hex()+hex.EncodeToString(args.Data[:4])]
+	}
+	if !ok {
+		return nil, errors.New("execution reverted")
+	}
+	return response, nil
+}
+
+func (eth *FakeEth) GetTransactionCount(address common.Address, block string) (hexutil.Uint64, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	nonce := uint64(len(eth.mined))
+	if block == "pending" {
+		for eth.mempool[nonce] != nil {
+			nonce++
+		}
+	}
+	return hexutil.Uint64(nonce), nil
+}
+
+// SendRawTransaction adds the transaction to the mempool, replacing the
+// transaction with the same nonce if its gas price is at least 10% higher.
+func (eth *FakeEth) SendRawTransaction(data hexutil.Bytes) (common.Hash, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(data, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if tx.Nonce() < uint64(len(eth.mined)) {
+		return common.Hash{}, errors.New("nonce too low")
+	}
+	if pending := eth.mempool[tx.Nonce()]; pending != nil {
+		if pending.Hash() == tx.Hash() {
+			return common.Hash{}, errors.New("known transaction: " + tx.Hash().Hex())
+		}
+		minGasPrice := new(big.Int).Div(new(big.Int).Mul(pending.GasPrice(), big.NewInt(110)), big.NewInt(100))
+		if tx.GasPrice().Cmp(minGasPrice) < 0 {
+			return common.Hash{}, errors.New("replacement transaction underpriced")
+		}
+	}
+	eth.mempool[tx.Nonce()] = tx
+	return tx.Hash(), nil
+}
+
+// drop removes the transaction with the nonce from the mempool.
+func (eth *FakeEth) drop(nonce uint64) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	delete(eth.mempool, nonce)
+}
+
+// mine mines the transactions in the mempool that are not blocked by a gap,
+// each in a new block, and returns the number of transactions mined.
+func (eth *FakeEth) mine() int {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	count := 0
+	for tx := eth.mempool[uint64(len(eth.mined))]; tx != nil; tx = eth.mempool[uint64(len(eth.mined))] {
+		delete(eth.mempool, tx.Nonce())
+		eth.head++
+		eth.mined = append(eth.mined, tx)
+		eth.minedBlocks[tx.Hash()] = eth.head
+		count++
+	}
+	return count
+}
+
+// mineWhen mines the mempool once the condition is met, unless the context
+// is done first.
+func (eth *FakeEth) mineWhen(ctx context.Context, cond func() bool) {
+	go func() {
+		for !cond() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		eth.mine()
+	}()
+}
+
+// mineWithLogs mines the mempool once the transaction with the nonce has been
+// sent, and its receipt has the logs.
+func (eth *FakeEth) mineWithLogs(ctx context.Context, nonce uint64, logs ...*types.Log) {
+	eth.mineWhen(ctx, func() bool {
+		tx := eth.pendingTx(nonce)
+		if tx == nil {
+			return false
+		}
+		eth.mu.Lock()
+		defer eth.mu.Unlock()
+		eth.receiptLogs[tx.Hash()] = logs
+		return true
+	})
+}
+
+// minedTxs returns the transactions that have been mined, in order.
+func (eth *FakeEth) minedTxs() []*types.Transaction {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	return append([]*types.Transaction{}, eth.mined...)
+}
+
+// pendingTx returns the transaction with the nonce in the mempool, or nil.
+func (eth *FakeEth) pendingTx(nonce uint64) *types.Transaction {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	return eth.mempool[nonce]
+}
+
+func (eth *FakeEth) GetTransactionByHash(hash common.Hash) (map[string]interface{}, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	var found *types.Transaction
+	for _, tx := range eth.mempool {
+		if tx.Hash() == hash {
+			found = tx
+		}
+	}
+	for _, tx := range eth.mined {
+		if tx.Hash() == hash {
+			found = tx
+		}
+	}
+	if found == nil {
+		return nil, nil
+	}
+
+	data, err := found.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if block, ok := eth.minedBlocks[hash]; ok {
+		result["blockNumber"] = hexutil.EncodeUint64(block)
+		result["blockHash"] = common.BigToHash(new(big.Int).SetUint64(block)).Hex()
+	}
+	return result, nil
+}
+
+func (eth *FakeEth) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	block, ok := eth.minedBlocks[hash]
+	if !ok {
+		return nil, nil
+	}
+	logs := []*types.Log{}
+	for _, log := range eth.receiptLogs[hash] {
+		log.TxHash = hash
+		log.BlockNumber = block
+		logs = append(logs, log)
+	}
+	return &types.Receipt{
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 21000,
+		GasUsed:           21000,
+		Logs:              logs,
+		TxHash:            hash,
+	}, nil
+}
+
+// GetCode returns some code for contracts with a canned response, so that
+// transactions to them can be built.
+func (eth *FakeEth) GetCode(address common.Address, block string) hexutil.Bytes {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	for key := range eth.responses {
+		if strings.HasPrefix(key, address.Hex()) {
+			return hexutil.Bytes{0x00}
+		}
+	}
+	return hexutil.Bytes{}
+}
+
+func (eth *FakeEth) GetStorageAt(address common.Address, slot common.Hash, block string) hexutil.Bytes {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	value := eth.storage[address.Hex()+slot.Hex()]
+	return value.Bytes()
+}
+
+func (eth *FakeEth) GetBalance(address common.Address, block string) *hexutil.Big {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	if balance, ok := eth.balances[address]; ok {
+		return (*hexutil.Big)(balance)
+	}
+	return (*hexutil.Big)(big.NewInt(0))
+}
+
+func (eth *FakeEth) EstimateGas(args FakeCallArgs) hexutil.Uint64 {
+	return 50000
+}
+
+func (eth *FakeEth) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1000000000))
+}
+
+func (eth *FakeEth) BlockNumber() hexutil.Uint64 {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	return hexutil.Uint64(eth.head)
+}
+
+func (eth *FakeEth) GetBlockByNumber(number string, full bool) (*types.Header, error) {
+	n, err := hexutil.DecodeBig(number)
+	if err != nil {
+		eth.mu.Lock()
+		eth.headQueries++
+		eth.mu.Unlock()
+		n = new(big.Int).SetUint64(uint64(eth.BlockNumber()))
+	}
+	return &types.Header{
+		Number:     n,
+		Time:       new(big.Int).Mul(n, big.NewInt(10)),
+		Difficulty: big.NewInt(0),
+		Extra:      []byte{},
+	}, nil
+}
+
+func (eth *FakeEth) GetLogs(args FakeFilterArgs) ([]types.Log, error) {
+	eth.mu.Lock()
+	defer eth.mu.Unlock()
+
+	eth.logQueries++
+	from, to := args.FromBlock.ToInt().Uint64(), args.ToBlock.ToInt().Uint64()
+	if eth.maxLogRange > 0 && to-from+1 > eth.maxLogRange {
+		return nil, errors.New("query returned more than 10000 results")
+	}
+	logs := []types.Log{}
+	for _, log := range eth.logs {
+		if log.BlockNumber < from || log.BlockNumber > to || !fakeMatch(log, args) {
+			continue
+		}
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+func fakeMatch(log types.Log, args FakeFilterArgs) bool {
+	if len(args.Addresses) > 0 && args.Addresses[0] != log.Address {
+		return false
+	}
+	for i, topics := range args.Topics {
+		if len(topics) == 0 {
+			continue
+		}
+		if i >= len(log.Topics) {
+			return false
+		}
+		matched := false
+		for _, topic := range topics {
+			matched = matched || topic == log.Topics[i]
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// FakeNet reports the network ID of the fake node, which is 1.
+type FakeNet struct{}
+
+func (net *FakeNet) Version() string {
+	return "1"
+}
+
+// abiString returns the ABI encoding of a string return value.
+func abiString(value string) []byte {
+	data := common.LeftPadBytes([]byte{0x20}, 32)
+	data = append(data, common.LeftPadBytes([]byte{byte(len(value))}, 32)...)
+	return append(data, common.RightPadBytes([]byte(value), (len(value)+31)/32*32)...)
+}
+
+// fakeTransfer returns a transaction function that sends wei to an address
+// with the gas price, or with the gas price of the account if it is nil.
+func fakeTransfer(client libeth.Client, to common.Address, wei int64, gasPrice *big.Int) func(*bind.TransactOpts) (*types.Transaction, error) {
+	return func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		price := gasPrice
+		if price == nil {
+			price = opts.GasPrice
+		}
+		if price == nil {
+			price = big.NewInt(1000000000)
+		}
+		tx := types.NewTransaction(opts.Nonce.Uint64(), to, big.NewInt(wei), 21000, price, nil)
+		signedTx, err := opts.Signer(types.HomesteadSigner{}, opts.From, tx)
+		if err != nil {
+			return nil, err
+		}
+		return signedTx, client.EthClient().SendTransaction(opts.Context, signedTx)
+	}
+}
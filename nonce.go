@@ -0,0 +1,431 @@
+package libeth
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// nextNonce returns the nonce for the next transaction, skipping the nonces
+// of prepared envelopes, which might still be broadcast. This function expects
+// the caller to hold the account's mutex.
+func (account *account) nextNonce() *big.Int {
+	for account.prepared[account.transactOpts.Nonce.Uint64()] {
+		account.useNonce()
+	}
+	return new(big.Int).Set(account.transactOpts.Nonce)
+}
+
+// useNonce marks the nonce returned by nextNonce as used. This function
+// expects the caller to hold the account's mutex.
+func (account *account) useNonce() {
+	account.transactOpts.Nonce.Add(account.transactOpts.Nonce, big.NewInt(1))
+}
+
+// resetNonce sets the next nonce. This function expects the caller to hold
+// the account's mutex.
+func (account *account) resetNonce(nonce uint64) {
+	account.transactOpts.Nonce = new(big.Int).SetUint64(nonce)
+}
+
+// trackTx records a transaction that has been broadcast, and journals it so
+// that it can be rebroadcast by RepairNonces. This function expects the caller
+// to hold the account's mutex.
+func (account *account) trackTx(tx *types.Transaction) {
+	account.inFlight[tx.Nonce()] = tx.Hash()
+	account.journal[tx.Nonce()] = tx
+}
+
+// supersededTx returns the transaction that replaced the transaction when the
+// nonces behind a dropped transaction were re-sequenced, or nil.
+func (account *account) supersededTx(tx *types.Transaction) *types.Transaction {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	return account.superseded[tx.Hash()]
+}
+
+// resignTx signs the transaction again with the same nonce and the given gas
+// price, so that it can replace the transaction while it is pending.
+func (account *account) resignTx(ctx context.Context, tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error) {
+	return account.signCopy(withReplaced(ctx, tx.Hash()), tx, tx.Nonce(), gasPrice)
+}
+
+// signCopy signs a copy of the transaction with the given nonce and gas
+// price, with the signing scheme of the transaction. It does not need the
+// account's mutex, so that a slow signer does not hold up other transactions.
+func (account *account) signCopy(ctx context.Context, tx *types.Transaction, nonce uint64, gasPrice *big.Int) (*types.Transaction, error) {
+	var unsignedTx *types.Transaction
+	if tx.To() == nil {
+		unsignedTx = types.NewContractCreation(nonce, tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	} else {
+		unsignedTx = types.NewTransaction(nonce, *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	}
+	return account.signerFn(ctx)(txSigner(tx), account.Address(), unsignedTx)
+}
+
+// replaceTx tracks a replacement that has been broadcast instead of the
+// transaction with the same nonce and payload that it replaced.
+func (account *account) replaceTx(replacement *types.Transaction) {
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	tx, ok := account.journal[replacement.Nonce()]
+	if !ok || account.inFlight[tx.Nonce()] != tx.Hash() {
+		return
+	}
+	if tx.Value().Cmp(replacement.Value()) == 0 && bytes.Equal(tx.Data(), replacement.Data()) && (tx.To() == nil) == (replacement.To() == nil) && (tx.To() == nil || *tx.To() == *replacement.To()) {
+		account.trackTx(replacement)
+	}
+}
+
+// settleTx stops tracking a transaction once waiting for it has finished. If
+// it was not mined, its nonce has not been used and the node no longer knows
+// about it, the transactions behind it are re-sequenced so that its nonce is
+// filled. The node is queried, and transactions are signed and sent, without
+// holding the account's mutex.
+func (account *account) settleTx(tx *types.Transaction, mined bool) {
+	dropped := func() bool {
+		account.mu.Lock()
+		defer account.mu.Unlock()
+
+		for hash, replacement := range account.superseded {
+			if replacement.Hash() == tx.Hash() {
+				delete(account.superseded, hash)
+			}
+		}
+		if account.inFlight[tx.Nonce()] != tx.Hash() {
+			return false
+		}
+		delete(account.inFlight, tx.Nonce())
+		if mined {
+			delete(account.journal, tx.Nonce())
+			return false
+		}
+		return tx.Nonce() < account.transactOpts.Nonce.Uint64()
+	}()
+	if !dropped {
+		return
+	}
+
+	// The context of the transaction might be done, so the checks use their
+	// own timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	nonce, err := account.client.EthClient().NonceAt(ctx, account.Address(), nil)
+	if err != nil || tx.Nonce() < nonce {
+		return
+	}
+	if _, _, err := account.client.EthClient().TransactionByHash(ctx, tx.Hash()); err != ethereum.NotFound {
+		return
+	}
+
+	account.resequence(ctx, tx)
+}
+
+// resequence fills the nonce of a dropped transaction. The transactions behind
+// it would be mined as soon as the nonce is filled, even if their operations
+// have given up on them and retried, so the nonce is never handed to another
+// operation. Instead, every journaled transaction behind the gap is superseded
+// by a copy signed with the nonce before it, which replaces the transaction
+// that had that nonce, and the last nonce is filled with a zero-value transfer
+// to the account itself. Replacements are sent from the last nonce down, so
+// that nothing behind the gap can be mined before it has been replaced. If a
+// replacement cannot be sent, the gap is filled with a zero-value transfer
+// instead, and the transactions that have not been replaced stay in place.
+// The account's mutex is only held to read the journal and to record the
+// transactions that have been sent, and must not be held by the caller.
+func (account *account) resequence(ctx context.Context, dropped *types.Transaction) {
+	account.mu.Lock()
+	// The nonce might have been filled while the node was queried
+	if journaled, ok := account.journal[dropped.Nonce()]; !ok || journaled.Hash() != dropped.Hash() || account.filling[dropped.Nonce()] {
+		account.mu.Unlock()
+		return
+	}
+	delete(account.journal, dropped.Nonce())
+
+	local := account.transactOpts.Nonce.Uint64()
+	if dropped.Nonce()+1 == local {
+		// Nothing has been sent behind the dropped transaction, so the nonce
+		// is simply allocated again
+		account.resetNonce(dropped.Nonce())
+		account.mu.Unlock()
+		return
+	}
+	behind := []*types.Transaction{}
+	for nonce := dropped.Nonce() + 1; nonce < local; nonce++ {
+		tx, ok := account.journal[nonce]
+		if !ok {
+			break
+		}
+		behind = append(behind, tx)
+	}
+	for nonce := dropped.Nonce(); nonce <= dropped.Nonce()+uint64(len(behind)); nonce++ {
+		account.filling[nonce] = true
+	}
+	gasPrice := account.transactOpts.GasPrice
+	account.mu.Unlock()
+
+	defer func() {
+		account.mu.Lock()
+		defer account.mu.Unlock()
+
+		for nonce := dropped.Nonce(); nonce <= dropped.Nonce()+uint64(len(behind)); nonce++ {
+			delete(account.filling, nonce)
+		}
+	}()
+
+	if len(behind) == 0 {
+		_ = account.fillNonce(ctx, dropped.Nonce(), gasPrice, txSigner(dropped))
+		return
+	}
+
+	last := behind[len(behind)-1]
+	if err := account.fillNonce(ctx, last.Nonce(), replacementGasPrice(last.GasPrice()), txSigner(last)); err != nil {
+		_ = account.fillNonce(ctx, dropped.Nonce(), gasPrice, txSigner(dropped))
+		return
+	}
+	account.mu.Lock()
+	delete(account.inFlight, last.Nonce())
+	account.mu.Unlock()
+
+	for i := len(behind) - 1; i >= 0; i-- {
+		nonce := dropped.Nonce() + uint64(i)
+		price := behind[i].GasPrice()
+		if i > 0 {
+			if minGasPrice := replacementGasPrice(behind[i-1].GasPrice()); price.Cmp(minGasPrice) < 0 {
+				price = minGasPrice
+			}
+		}
+		replacement, err := account.signCopy(withReplaced(ctx, behind[i].Hash()), behind[i], nonce, price)
+		if err == nil {
+			err = account.client.EthClient().SendTransaction(ctx, replacement)
+		}
+		if err != nil {
+			_ = account.fillNonce(ctx, dropped.Nonce(), gasPrice, txSigner(dropped))
+			return
+		}
+		account.mu.Lock()
+		account.superseded[behind[i].Hash()] = replacement
+		account.trackTx(replacement)
+		account.mu.Unlock()
+	}
+}
+
+// NonceReport compares the nonce of the next transaction of an account with
+// the nonces known to the Ethereum node. Nonces between the pending nonce and
+// the local nonce that are not in the mempool are missing, and block all
+// transactions behind them.
+type NonceReport struct {
+	Local   uint64
+	Pending uint64
+	Latest  uint64
+
+	// Missing lists the nonces of the gaps that were found. Rebroadcast and
+	// Filled list the missing nonces that were repaired by sending their
+	// journaled transaction again, or by sending a zero-value transfer to the
+	// account itself.
+	Missing     []uint64
+	Rebroadcast []uint64
+	Filled      []uint64
+
+	// Prepared lists the gaps that were left alone, because an envelope has
+	// been prepared for them and might still be broadcast.
+	Prepared []uint64
+
+	// Err is the error that stopped the repair, if any.
+	Err error
+}
+
+// RepairNonces finds nonce gaps and fills them. Journaled transactions are
+// rebroadcast if possible, otherwise the gap is filled with a zero-value
+// transfer to the account itself, unless an envelope has been prepared for
+// it. If the local nonce is behind the pending nonce, it is moved forward.
+func (account *account) RepairNonces(ctx context.Context) (NonceReport, error) {
+	report, err := account.repairNonces(ctx, true)
+	report.Err = err
+	return report, err
+}
+
+// repairNonces finds nonce gaps, and repairs them if repair is set. The node
+// is queried, and gaps are filled, without holding the account's mutex, which
+// is only held to read and update the state of the account.
+func (account *account) repairNonces(ctx context.Context, repair bool) (NonceReport, error) {
+	account.mu.Lock()
+	report := NonceReport{
+		Local: account.transactOpts.Nonce.Uint64(),
+	}
+	journal := make(map[uint64]*types.Transaction, len(account.journal))
+	for nonce, tx := range account.journal {
+		journal[nonce] = tx
+	}
+	prepared := make(map[uint64]bool, len(account.prepared))
+	for nonce := range account.prepared {
+		prepared[nonce] = true
+	}
+	account.mu.Unlock()
+
+	var err error
+	if report.Latest, err = account.client.EthClient().NonceAt(ctx, account.Address(), nil); err != nil {
+		return report, err
+	}
+	if report.Pending, err = account.client.EthClient().PendingNonceAt(ctx, account.Address()); err != nil {
+		return report, err
+	}
+
+	if repair {
+		account.mu.Lock()
+		// Transactions with nonces below the latest nonce are final
+		for nonce := range account.journal {
+			if nonce < report.Latest {
+				delete(account.journal, nonce)
+			}
+		}
+		for nonce := range account.prepared {
+			if nonce < report.Latest {
+				delete(account.prepared, nonce)
+			}
+		}
+		for hash, replacement := range account.superseded {
+			if replacement.Nonce() < report.Latest {
+				delete(account.superseded, hash)
+			}
+		}
+		// Nonces might have been allocated while the node was queried
+		if report.Local <= report.Pending && account.transactOpts.Nonce.Uint64() == report.Local {
+			account.resetNonce(report.Pending)
+		}
+		account.mu.Unlock()
+	}
+
+	for nonce := report.Pending; nonce < report.Local; nonce++ {
+		if prepared[nonce] {
+			report.Prepared = append(report.Prepared, nonce)
+			continue
+		}
+		tx, ok := journal[nonce]
+		if ok {
+			// Queued transactions are still known to the node and only wait
+			// for the gap before them
+			if _, _, err := account.client.EthClient().TransactionByHash(ctx, tx.Hash()); err == nil {
+				continue
+			}
+		}
+		report.Missing = append(report.Missing, nonce)
+		if !repair {
+			continue
+		}
+
+		if ok {
+			if err := account.client.EthClient().SendTransaction(ctx, tx); err == nil {
+				report.Rebroadcast = append(report.Rebroadcast, nonce)
+				continue
+			}
+		}
+		fill, gasPrice, signer := func() (bool, *big.Int, types.Signer) {
+			account.mu.Lock()
+			defer account.mu.Unlock()
+
+			// The gap might have been filled while the node was queried
+			if current, exists := account.journal[nonce]; account.filling[nonce] || exists && (!ok || current.Hash() != tx.Hash()) {
+				return false, nil, nil
+			}
+			account.filling[nonce] = true
+			if ok {
+				return true, account.transactOpts.GasPrice, txSigner(tx)
+			}
+			return true, account.transactOpts.GasPrice, account.txSigner()
+		}()
+		if !fill {
+			continue
+		}
+		err := account.fillNonce(ctx, nonce, gasPrice, signer)
+		account.mu.Lock()
+		delete(account.filling, nonce)
+		account.mu.Unlock()
+		if err != nil {
+			return report, err
+		}
+		report.Filled = append(report.Filled, nonce)
+	}
+	return report, nil
+}
+
+// MonitorNonces repairs the nonces of the account on every interval until the
+// context is done, and sends the reports that have missing nonces or an error
+// to the channel. Errors do not stop the monitor.
+func (account *account) MonitorNonces(ctx context.Context, interval time.Duration, reports chan<- NonceReport) error {
+	return monitorNonces(ctx, interval, reports, account.RepairNonces)
+}
+
+// monitorNonces calls repair on every interval until the context is done, and
+// sends the reports that have missing nonces or an error to the channel.
+func monitorNonces(ctx context.Context, interval time.Duration, reports chan<- NonceReport, repair func(context.Context) (NonceReport, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, _ := repair(ctx)
+		if (len(report.Missing) > 0 || report.Err != nil) && reports != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case reports <- report:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// fillNonce sends a zero-value transfer from the account to itself with the
+// given nonce, gas price and signing scheme, and journals it. A nil gas price
+// selects the gas price suggested by the node. The transfer is signed and sent
+// without holding the account's mutex, which must not be held by the caller.
+func (account *account) fillNonce(ctx context.Context, nonce uint64, gasPrice *big.Int, txSigner types.Signer) error {
+	if gasPrice == nil {
+		var err error
+		if gasPrice, err = account.client.EthClient().SuggestGasPrice(ctx); err != nil {
+			return err
+		}
+	}
+	tx := types.NewTransaction(nonce, account.Address(), big.NewInt(0), 21000, gasPrice, nil)
+	signedTx, err := account.signerFn(ctx)(txSigner, account.Address(), tx)
+	if err != nil {
+		return err
+	}
+	if err := account.client.EthClient().SendTransaction(ctx, signedTx); err != nil {
+		return err
+	}
+
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	account.journal[nonce] = signedTx
+	return nil
+}
+
+// txSigner returns the signing scheme of the transactions of the account,
+// which is that of its latest journaled transaction, or the scheme that bound
+// contracts sign with if it has none. This function expects the caller to hold
+// the account's mutex.
+func (account *account) txSigner() types.Signer {
+	var latest *types.Transaction
+	for _, tx := range account.journal {
+		if latest == nil || tx.Nonce() > latest.Nonce() {
+			latest = tx
+		}
+	}
+	if latest == nil {
+		return types.HomesteadSigner{}
+	}
+	return txSigner(latest)
+}
@@ -0,0 +1,263 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+// blockingSigner blocks the signing of zero-value transfers to the account
+// itself until it is released, and signals on the channel when it blocks.
+type blockingSigner struct {
+	libeth.Signer
+	blocked chan struct{}
+	release chan struct{}
+}
+
+func (signer *blockingSigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	if tx.To() != nil && *tx.To() == signer.Address() && tx.Value().Sign() == 0 {
+		signer.blocked <- struct{}{}
+		<-signer.release
+	}
+	return signer.Signer.SignTx(ctx, txSigner, tx)
+}
+
+var _ = Describe("nonces", func() {
+
+	recipient := common.HexToAddress("0xb0b")
+
+	// executed returns the number of mined transfers of the value to the
+	// recipient.
+	executed := func(eth *FakeEth, wei int64) int {
+		count := 0
+		for _, tx := range eth.minedTxs() {
+			if tx.To() != nil && *tx.To() == recipient && tx.Value().Int64() == wei {
+				count++
+			}
+		}
+		return count
+	}
+
+	Context("when a transaction in the middle of the pipeline is dropped", func() {
+		It("should re-sequence the transactions behind it and execute every operation once", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// Three operations transfer 1, 2 and 3 wei with nonces 0, 1 and 2
+			errs := make(chan error, 3)
+			for wei := int64(1); wei <= 3; wei++ {
+				wei := wei
+				options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+				options.Watch.PollInterval = 10 * time.Millisecond
+				options.Watch.MissingPolls = 3
+				options.Watch.MaxRebroadcasts = -1
+				options.Retry.InitialDelay = 10 * time.Millisecond
+				options.PostConditionTimeout = 100 * time.Millisecond
+				options.PostConditionCheck = func() bool { return executed(eth, wei) > 0 }
+				go func() {
+					_, err := account.TransactWithOptions(ctx, fakeTransfer(client, recipient, wei, nil), options)
+					errs <- err
+				}()
+				Eventually(func() bool { return eth.pendingTx(uint64(wei-1)) != nil }).Should(BeTrue())
+			}
+
+			// Once the second transaction has been dropped, the third takes
+			// its nonce, the third nonce is filled, and the second operation
+			// retries with the fourth nonce
+			eth.drop(1)
+			eth.mineWhen(ctx, func() bool { return eth.pendingTx(3) != nil })
+			for i := 0; i < 3; i++ {
+				Eventually(errs, 10*time.Second).Should(Receive(BeNil()))
+			}
+
+			mined := eth.minedTxs()
+			Expect(mined).Should(HaveLen(4))
+			Expect(mined[1].Value()).Should(Equal(big.NewInt(3)))
+			Expect(*mined[2].To()).Should(Equal(account.Address()))
+			Expect(mined[2].Value().Sign()).Should(BeZero())
+			for wei := int64(1); wei <= 3; wei++ {
+				Expect(executed(eth, wei)).Should(Equal(1))
+			}
+		})
+	})
+
+	Context("when the node reports that the nonce is too high", func() {
+		It("should resync with the pending nonce without reusing the nonces of other transactions", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// Nonces 0 and 5 are pending, so the pending nonce is 1
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 1, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Eventually(func() bool { return eth.pendingTx(0) != nil }).Should(BeTrue())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Nonce = big.NewInt(5)
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 5, nil), options)
+			Eventually(func() bool { return eth.pendingTx(5) != nil }).Should(BeTrue())
+
+			// The node rejects nonces above the pending nonce
+			tried := []uint64{}
+			transfer := func(tops *bind.TransactOpts) (*types.Transaction, error) {
+				tried = append(tried, tops.Nonce.Uint64())
+				if tops.Nonce.Uint64() > 1 {
+					return nil, core.ErrNonceTooHigh
+				}
+				return fakeTransfer(client, recipient, 2, nil)(tops)
+			}
+			go account.TransactWithOptions(ctx, transfer, libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Eventually(func() bool { return eth.pendingTx(1) != nil }).Should(BeTrue())
+			Expect(tried).Should(Equal([]uint64{6, 1}))
+		})
+	})
+
+	Context("when repairing nonces", func() {
+		It("should rebroadcast and fill gaps, but skip prepared envelopes", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// Nonce 0 is prepared for an offline signer, nonce 1 is sent and
+			// then dropped, nonce 2 is never used and nonce 3 is pending
+			_, err = account.Prepare(ctx, libeth.Fast, fakeTransfer(client, recipient, 1, nil))
+			Expect(err).ShouldNot(HaveOccurred())
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 2, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Eventually(func() bool { return eth.pendingTx(1) != nil }).Should(BeTrue())
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Nonce = big.NewInt(3)
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 4, nil), options)
+			Eventually(func() bool { return eth.pendingTx(3) != nil }).Should(BeTrue())
+			eth.drop(1)
+
+			report, err := account.RepairNonces(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Local).Should(Equal(uint64(4)))
+			Expect(report.Prepared).Should(Equal([]uint64{0}))
+			Expect(report.Missing).Should(Equal([]uint64{1, 2}))
+			Expect(report.Rebroadcast).Should(Equal([]uint64{1}))
+			Expect(report.Filled).Should(Equal([]uint64{2}))
+			Expect(eth.pendingTx(0)).Should(BeNil())
+			Expect(eth.pendingTx(1).Value()).Should(Equal(big.NewInt(2)))
+			Expect(*eth.pendingTx(2).To()).Should(Equal(account.Address()))
+		})
+
+		It("should not hold up other transactions while a gap is filled", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := &blockingSigner{
+				Signer:  libeth.NewPrivateKeySigner(key),
+				blocked: make(chan struct{}, 1),
+				release: make(chan struct{}),
+			}
+			account, err := libeth.NewAccountWithSigner(client, signer)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			// Nonce 0 is never used, and nonce 1 is pending
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Nonce = big.NewInt(1)
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 1, nil), options)
+			Eventually(func() bool { return eth.pendingTx(1) != nil }).Should(BeTrue())
+
+			// While the gap is being signed, the next transaction is sent
+			reports := make(chan libeth.NonceReport, 1)
+			go func() {
+				report, _ := account.RepairNonces(ctx)
+				reports <- report
+			}()
+			Eventually(signer.blocked, 5*time.Second).Should(Receive())
+			go account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 2, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Eventually(func() bool { return eth.pendingTx(2) != nil }, 5*time.Second).Should(BeTrue())
+
+			close(signer.release)
+			var report libeth.NonceReport
+			Eventually(reports, 5*time.Second).Should(Receive(&report))
+			Expect(report.Filled).Should(Equal([]uint64{0}))
+			Expect(*eth.pendingTx(0).To()).Should(Equal(account.Address()))
+		})
+
+		It("should send errors to the monitor's channel", func() {
+			server, _ := newFakeNode()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			server.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			reports := make(chan libeth.NonceReport)
+			go account.MonitorNonces(ctx, 10*time.Millisecond, reports)
+			var report libeth.NonceReport
+			Eventually(reports, 5*time.Second).Should(Receive(&report))
+			Expect(report.Err).Should(HaveOccurred())
+		})
+
+		It("should only report gaps for a dry-run account", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The account starts with a pending transaction, which is dropped
+			tx, err := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(1), 21000, big.NewInt(1000000000), nil), types.HomesteadSigner{}, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(client.EthClient().SendTransaction(ctx, tx)).Should(Succeed())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.drop(0)
+
+			report, err := account.RepairNonces(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Missing).Should(Equal([]uint64{0}))
+			Expect(report.Filled).Should(BeEmpty())
+
+			reports := make(chan libeth.NonceReport)
+			go account.MonitorNonces(ctx, 10*time.Millisecond, reports)
+			Eventually(reports).Should(Receive())
+			Expect(eth.pendingTx(0)).Should(BeNil())
+		})
+	})
+})
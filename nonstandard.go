@@ -0,0 +1,190 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// ErrTokenReturnedFalse indicates that a token would return false instead of
+// reverting when the transaction is executed.
+var ErrTokenReturnedFalse = errors.New("token returned false")
+
+// TransferNotExecutedError is returned when a transfer was mined, but neither
+// emitted a Transfer event nor changed the balance of the recipient. This is
+// how tokens that return false, or that return nothing, fail.
+type TransferNotExecutedError struct {
+	Token  common.Address
+	From   common.Address
+	To     common.Address
+	Value  *big.Int
+	TxHash common.Hash
+}
+
+func (err *TransferNotExecutedError) Error() string {
+	return fmt.Sprintf("transfer of %v %v tokens from %v to %v in tx %v was mined but did not move any tokens", err.Value, err.Token.Hex(), err.From.Hex(), err.To.Hex(), err.TxHash.Hex())
+}
+
+// ApprovalNotExecutedError is returned when an approval was mined, but
+// neither emitted an Approval event nor set the allowance.
+type ApprovalNotExecutedError struct {
+	Token   common.Address
+	Owner   common.Address
+	Spender common.Address
+	Value   *big.Int
+	TxHash  common.Hash
+}
+
+func (err *ApprovalNotExecutedError) Error() string {
+	return fmt.Sprintf("approval of %v %v tokens from %v to %v in tx %v was mined but did not set the allowance", err.Value, err.Token.Hex(), err.Owner.Hex(), err.Spender.Hex(), err.TxHash.Hex())
+}
+
+var (
+	erc20ABI, _ = abi.JSON(strings.NewReader(bindings.ERC20ABI))
+
+	transferEventID = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+	approvalEventID = crypto.Keccak256Hash([]byte("Approval(address,address,uint256)"))
+)
+
+// simulate calls the method of the token from the account, and returns
+// ErrTokenReturnedFalse if it returns false. Tokens that return nothing are
+// accepted, and so are calls that fail, because the transaction will fail
+// the same way when its gas is estimated.
+func (erc20 *erc20) simulate(ctx context.Context, method string, args ...interface{}) error {
+	data, err := erc20ABI.Pack(method, args...)
+	if err != nil {
+		return err
+	}
+	resp, err := erc20.client.EthClient().CallContract(ctx, ethereum.CallMsg{
+		From: erc20.account.Address(),
+		To:   &erc20.address,
+		Data: data,
+	}, nil)
+	if err == nil && len(resp) == 32 && new(big.Int).SetBytes(resp).Sign() == 0 {
+		return ErrTokenReturnedFalse
+	}
+	return nil
+}
+
+// verifyTransfer checks that the mined transaction moved tokens from one
+// address to another, by looking for a matching Transfer event in its
+// receipt, or otherwise for an increase of the balance of the recipient by
+// exactly the value in its block. Return values are not used, because
+// non-standard tokens do not have them. Tokens that charge a fee on transfers
+// emit a Transfer event of less than the value, which is accepted.
+func (erc20 *erc20) verifyTransfer(ctx context.Context, tx *types.Transaction, from, to common.Address, value *big.Int) error {
+	if tx == nil || value.Sign() == 0 || from == to {
+		return nil
+	}
+	if _, ok := erc20.account.(DryRunAccount); ok {
+		return nil
+	}
+
+	receipt, block, err := erc20.receipt(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if transferred := erc20.eventValue(receipt, transferEventID, from, to); transferred != nil && transferred.Sign() > 0 && transferred.Cmp(value) <= 0 {
+		return nil
+	}
+
+	var before, after *big.Int
+	if err := erc20.client.Get(ctx, func() (err error) {
+		if before, err = erc20.bindings.BalanceOf(&bind.CallOpts{BlockNumber: new(big.Int).Sub(block, big.NewInt(1)), Context: ctx}, to); err != nil {
+			return err
+		}
+		after, err = erc20.bindings.BalanceOf(&bind.CallOpts{BlockNumber: block, Context: ctx}, to)
+		return err
+	}); err != nil {
+		return err
+	}
+	if new(big.Int).Sub(after, before).Cmp(value) == 0 {
+		return nil
+	}
+	return &TransferNotExecutedError{
+		Token:  erc20.address,
+		From:   from,
+		To:     to,
+		Value:  value,
+		TxHash: tx.Hash(),
+	}
+}
+
+// verifyApproval checks that the mined transaction set the allowance, by
+// looking for a matching Approval event in its receipt, or otherwise by
+// reading the allowance at its block.
+func (erc20 *erc20) verifyApproval(ctx context.Context, tx *types.Transaction, owner, spender common.Address, value *big.Int) error {
+	if tx == nil {
+		return nil
+	}
+	if _, ok := erc20.account.(DryRunAccount); ok {
+		return nil
+	}
+
+	receipt, block, err := erc20.receipt(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if approved := erc20.eventValue(receipt, approvalEventID, owner, spender); approved != nil && approved.Cmp(value) == 0 {
+		return nil
+	}
+
+	var allowance *big.Int
+	if err := erc20.client.Get(ctx, func() (err error) {
+		allowance, err = erc20.bindings.Allowance(&bind.CallOpts{BlockNumber: block, Context: ctx}, owner, spender)
+		return err
+	}); err != nil {
+		return err
+	}
+	if allowance.Cmp(value) == 0 {
+		return nil
+	}
+	return &ApprovalNotExecutedError{
+		Token:   erc20.address,
+		Owner:   owner,
+		Spender: spender,
+		Value:   value,
+		TxHash:  tx.Hash(),
+	}
+}
+
+// receipt returns the receipt of a mined transaction and its block number.
+func (erc20 *erc20) receipt(ctx context.Context, tx *types.Transaction) (*types.Receipt, *big.Int, error) {
+	var receipt *types.Receipt
+	if err := erc20.client.Get(ctx, func() (err error) {
+		receipt, err = erc20.client.EthClient().TransactionReceipt(ctx, tx.Hash())
+		return err
+	}); err != nil {
+		return nil, nil, err
+	}
+	block, err := erc20.client.TxBlockNumber(ctx, tx.Hash().Hex())
+	if err != nil {
+		return nil, nil, err
+	}
+	return receipt, block, nil
+}
+
+// eventValue returns the value of the first event of the token with the two
+// indexed addresses in the receipt, or nil if there is none.
+func (erc20 *erc20) eventValue(receipt *types.Receipt, eventID common.Hash, first, second common.Address) *big.Int {
+	for _, log := range receipt.Logs {
+		if log.Address != erc20.address || len(log.Topics) != 3 || log.Topics[0] != eventID {
+			continue
+		}
+		if common.BytesToAddress(log.Topics[1].Bytes()) == first &&
+			common.BytesToAddress(log.Topics[2].Bytes()) == second {
+			return new(big.Int).SetBytes(log.Data)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ErrNoSigner indicates that the account does not hold a key and can only
+// prepare unsigned transactions.
+var ErrNoSigner = errors.New("account has no signer")
+
+// errTxPrepared is returned from the signer of a transaction that is being
+// prepared, to stop it from being sent.
+var errTxPrepared = errors.New("transaction prepared")
+
+// UnsignedTx is an envelope of a transaction that has been prepared by an
+// online Account and can be signed by an OfflineAccount. A nil chain ID means
+// the transaction is signed without replay protection.
+type UnsignedTx struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	GasLimit hexutil.Uint64  `json:"gas"`
+	Value    *hexutil.Big    `json:"value"`
+	Data     hexutil.Bytes   `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId,omitempty"`
+}
+
+// Transaction returns the unsigned transaction.
+func (unsignedTx *UnsignedTx) Transaction() *types.Transaction {
+	if unsignedTx.To == nil {
+		return types.NewContractCreation(uint64(unsignedTx.Nonce), unsignedTx.Value.ToInt(), uint64(unsignedTx.GasLimit), unsignedTx.GasPrice.ToInt(), unsignedTx.Data)
+	}
+	return types.NewTransaction(uint64(unsignedTx.Nonce), *unsignedTx.To, unsignedTx.Value.ToInt(), uint64(unsignedTx.GasLimit), unsignedTx.GasPrice.ToInt(), unsignedTx.Data)
+}
+
+// TxSigner returns the transaction signing scheme for the chain ID.
+func (unsignedTx *UnsignedTx) TxSigner() types.Signer {
+	if unsignedTx.ChainID == nil {
+		return types.HomesteadSigner{}
+	}
+	return types.NewEIP155Signer(unsignedTx.ChainID.ToInt())
+}
+
+// SignedTx is an envelope of a transaction that has been signed by an
+// OfflineAccount and can be broadcast by a Client.
+type SignedTx struct {
+	From common.Address `json:"from"`
+	Hash common.Hash    `json:"hash"`
+	Raw  hexutil.Bytes  `json:"raw"`
+}
+
+// Transaction decodes the signed transaction.
+func (signedTx *SignedTx) Transaction() (*types.Transaction, error) {
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(signedTx.Raw, tx); err != nil {
+		return nil, err
+	}
+	if tx.Hash() != signedTx.Hash {
+		return nil, errors.New("transaction hash does not match")
+	}
+	return tx, nil
+}
+
+// OfflineAccount is an Ethereum external account that signs transactions
+// without being connected to an Ethereum client. It is meant to run on an
+// air-gapped machine, signing envelopes prepared by an online Account.
+type OfflineAccount interface {
+
+	// Address returns the ethereum address of the account holder.
+	Address() common.Address
+
+	// SignTx signs the unsigned transaction envelope.
+	SignTx(ctx context.Context, unsignedTx *UnsignedTx) (*SignedTx, error)
+
+	// Sign the given message with the account's private key.
+	Sign(msgHash []byte) ([]byte, error)
+
+	// SignPersonalMessage signs the EIP-191 hash of the message with the
+	// account's private key.
+	SignPersonalMessage(msg []byte) ([]byte, error)
+
+	// SignTypedData signs the EIP-712 digest of the typed data with the
+	// account's private key.
+	SignTypedData(typedData TypedData) ([]byte, error)
+}
+
+type offlineAccount struct {
+	signer Signer
+}
+
+// NewOfflineAccount returns an account for the provided signer which is not
+// connected to an Ethereum client.
+func NewOfflineAccount(signer Signer) OfflineAccount {
+	return &offlineAccount{
+		signer: signer,
+	}
+}
+
+func (account *offlineAccount) Address() common.Address {
+	return account.signer.Address()
+}
+
+func (account *offlineAccount) SignTx(ctx context.Context, unsignedTx *UnsignedTx) (*SignedTx, error) {
+	if unsignedTx.From != account.Address() {
+		return nil, ErrSignerAddressMismatch
+	}
+	tx, err := account.signer.SignTx(ctx, unsignedTx.TxSigner(), unsignedTx.Transaction())
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedTx{
+		From: unsignedTx.From,
+		Hash: tx.Hash(),
+		Raw:  raw,
+	}, nil
+}
+
+func (account *offlineAccount) Sign(msgHash []byte) ([]byte, error) {
+	return account.signer.SignHash(context.Background(), msgHash)
+}
+
+func (account *offlineAccount) SignPersonalMessage(msg []byte) ([]byte, error) {
+	return SignPersonalMessage(context.Background(), account.signer, msg)
+}
+
+func (account *offlineAccount) SignTypedData(typedData TypedData) ([]byte, error) {
+	return SignTypedData(context.Background(), account.signer, typedData)
+}
+
+// watchSigner is a Signer for an address whose key is held elsewhere. It
+// refuses to sign anything.
+type watchSigner struct {
+	address common.Address
+}
+
+// NewWatchAccount returns an account for an address whose key is not
+// available, which is connected to an Ethereum client. It can read state and
+// prepare unsigned transactions, but any attempt to sign fails with
+// ErrNoSigner.
+func NewWatchAccount(client Client, address common.Address) (Account, error) {
+	return NewAccountWithSigner(client, &watchSigner{address: address})
+}
+
+func (signer *watchSigner) Address() common.Address {
+	return signer.address
+}
+
+func (signer *watchSigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	return nil, ErrNoSigner
+}
+
+func (signer *watchSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return nil, ErrNoSigner
+}
+
+func (signer *watchSigner) SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error) {
+	return nil, ErrNoSigner
+}
+
+// Prepare builds an unsigned transaction envelope by executing 'f' with the
+// account's current nonce and gas price, without signing or sending the
+// transaction. The account's nonce is advanced, so that several envelopes can
+// be prepared in a row; ResetToPendingNonce should be called if the envelopes
+// are abandoned.
+func (account *account) Prepare(ctx context.Context, speed TxExecutionSpeed, f func(*bind.TransactOpts) (*types.Transaction, error)) (*UnsignedTx, error) {
+	chainID, err := account.client.ethClient.NetworkID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	gasPrice, _ := SuggestedGasPrice(speed)
+
+	account.mu.Lock()
+	defer account.mu.Unlock()
+
+	account.updateGasPrice(gasPrice)
+
+	var unsignedTx *UnsignedTx
+	nonce := account.nextNonce()
+	transactor := &bind.TransactOpts{
+		From: account.transactOpts.From,
+		Signer: func(_ types.Signer, from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			unsignedTx = &UnsignedTx{
+				From:     from,
+				To:       tx.To(),
+				Nonce:    hexutil.Uint64(tx.Nonce()),
+				GasPrice: (*hexutil.Big)(tx.GasPrice()),
+				GasLimit: hexutil.Uint64(tx.Gas()),
+				Value:    (*hexutil.Big)(tx.Value()),
+				Data:     tx.Data(),
+				ChainID:  (*hexutil.Big)(chainID),
+			}
+			return nil, errTxPrepared
+		},
+		Nonce:    nonce,
+		Value:    big.NewInt(0),
+		GasLimit: account.transactOpts.GasLimit,
+		Context:  ctx,
+	}
+	if account.transactOpts.GasPrice != nil {
+		transactor.GasPrice = big.NewInt(0).Set(account.transactOpts.GasPrice)
+	}
+
+	if _, err := f(transactor); err != errTxPrepared {
+		if err == nil {
+			err = errors.New("transaction was not signed by the transactor")
+		}
+		return nil, err
+	}
+	account.useNonce()
+	account.prepared[nonce.Uint64()] = true
+	return unsignedTx, nil
+}
+
+// PrepareTransfer builds an unsigned transaction envelope that transfers eth
+// from the account to an ethereum address. If sendAll is set, the value is
+// replaced by the balance of the account minus the cost of gas.
+func (account *account) PrepareTransfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, sendAll bool) (*UnsignedTx, error) {
+	return account.Prepare(ctx, speed, account.transferTx(ctx, to, value, sendAll))
+}
+
+// BroadcastSigned submits a signed transaction envelope and waits for it to be
+// mined and confirmed.
+func (client *Client) BroadcastSigned(ctx context.Context, signedTx *SignedTx, confirmBlocks int64) (*types.Receipt, error) {
+	tx, err := signedTx.Transaction()
+	if err != nil {
+		return nil, err
+	}
+	return client.Broadcast(ctx, tx, confirmBlocks)
+}
@@ -0,0 +1,97 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("offline accounts", func() {
+
+	Context("when broadcasting a signed transaction", func() {
+		It("should poll for confirmations once a second", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0xb0b"), big.NewInt(1), 21000, big.NewInt(1000000000), nil), types.HomesteadSigner{}, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 2500*time.Millisecond)
+			defer cancel()
+
+			// The transaction is mined, but no block is mined on top of it
+			eth.mineWhen(ctx, func() bool { return eth.pendingTx(0) != nil })
+			_, err = client.Broadcast(ctx, tx, 1)
+			Expect(err).Should(Equal(context.DeadlineExceeded))
+			eth.mu.Lock()
+			defer eth.mu.Unlock()
+			Expect(eth.headQueries).Should(BeNumerically("<=", 4))
+		})
+	})
+
+	Context("when signing an unsigned transaction envelope", func() {
+		It("should produce a signed envelope that survives serialisation", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account := libeth.NewOfflineAccount(libeth.NewPrivateKeySigner(key))
+
+			to := common.HexToAddress("0x1")
+			unsignedTx := libeth.UnsignedTx{
+				From:     account.Address(),
+				To:       &to,
+				Nonce:    7,
+				GasPrice: (*hexutil.Big)(big.NewInt(1000000000)),
+				GasLimit: 21000,
+				Value:    (*hexutil.Big)(big.NewInt(100)),
+				ChainID:  (*hexutil.Big)(big.NewInt(42)),
+			}
+			data, err := json.Marshal(unsignedTx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			var decodedUnsignedTx libeth.UnsignedTx
+			Expect(json.Unmarshal(data, &decodedUnsignedTx)).Should(Succeed())
+			signedTx, err := account.SignTx(context.Background(), &decodedUnsignedTx)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			data, err = json.Marshal(signedTx)
+			Expect(err).ShouldNot(HaveOccurred())
+			var decodedSignedTx libeth.SignedTx
+			Expect(json.Unmarshal(data, &decodedSignedTx)).Should(Succeed())
+
+			tx, err := decodedSignedTx.Transaction()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tx.Nonce()).Should(Equal(uint64(7)))
+			Expect(tx.Value()).Should(Equal(big.NewInt(100)))
+			Expect(*tx.To()).Should(Equal(to))
+			from, err := types.Sender(types.NewEIP155Signer(big.NewInt(42)), tx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(from).Should(Equal(account.Address()))
+		})
+
+		It("should refuse to sign envelopes for other addresses", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account := libeth.NewOfflineAccount(libeth.NewPrivateKeySigner(key))
+
+			unsignedTx := libeth.UnsignedTx{
+				From:     common.HexToAddress("0x2"),
+				GasPrice: (*hexutil.Big)(big.NewInt(1)),
+				Value:    (*hexutil.Big)(big.NewInt(0)),
+			}
+			_, err = account.SignTx(context.Background(), &unsignedTx)
+			Expect(err).Should(Equal(libeth.ErrSignerAddressMismatch))
+		})
+	})
+})
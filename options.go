@@ -0,0 +1,158 @@
+package libeth
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Default values of TransactOptions.
+const (
+	DefaultTxTimeout            = 10 * time.Minute
+	DefaultPostConditionTimeout = 180 * time.Second
+	DefaultRetryInitialDelay    = time.Second
+	DefaultRetryMaxDelay        = 30 * time.Second
+	DefaultRetryMultiplier      = 1.6
+	DefaultTxMaxRebroadcasts    = 3
+)
+
+// RetryPolicy defines the delay between two attempts of a transaction. The
+// delay starts at InitialDelay and is multiplied by Multiplier after every
+// attempt, saturating at MaxDelay. A MaxAttempts of zero retries until the
+// context is done.
+type RetryPolicy struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	MaxAttempts  int
+}
+
+// TransactOptions configure a transaction executed by Account.Transact. The
+// zero value of a field selects its default.
+type TransactOptions struct {
+	Speed              TxExecutionSpeed
+	PreConditionCheck  func() bool
+	PostConditionCheck func() bool
+	ConfirmBlocks      int64
+
+	// PreCondition and PostCondition are checked in addition to the check
+	// functions. The pre-condition is evaluated at the latest block, and the
+	// post-condition at the block of the receipt of the transaction. Without
+	// a PostConditionCheck, the post-condition is re-evaluated whenever a
+	// block relevant to its dependencies arrives, instead of every second.
+	PreCondition  Condition
+	PostCondition Condition
+
+	// Value is the wei sent with the transaction, for payable contract calls.
+	Value *big.Int
+
+	// GasLimit overrides the gas limit of the account, which is usually
+	// estimated.
+	GasLimit uint64
+
+	// Nonce overrides the nonce managed by the account. Nonce errors are not
+	// retried when it is set.
+	Nonce *big.Int
+
+	// MaxGasPrice is a ceiling for the suggested gas price.
+	MaxGasPrice *big.Int
+
+	Retry RetryPolicy
+
+	// Idempotent stops retries from sending a new transaction while the
+	// transaction of an earlier attempt is pending, or once it has been mined
+	// successfully. The earlier transaction is watched again instead, so that
+	// the effect of the transaction happens at most once.
+	Idempotent bool
+
+	// TxTimeout bounds the time spent sending one attempt of the transaction
+	// and waiting for it to be mined. PostConditionTimeout bounds the time
+	// spent waiting for the post-condition check to pass after an attempt.
+	TxTimeout            time.Duration
+	PostConditionTimeout time.Duration
+
+	// Watch configures how each transaction is watched until it is mined.
+	// Zero fields select those of DefaultTxWatchOptions, except for
+	// MaxRebroadcasts which defaults to DefaultTxMaxRebroadcasts, and can be
+	// negative to disable rebroadcasting. Stuck transactions are re-signed
+	// with a higher gas price, up to MaxGasPrice, so Resign is ignored.
+	Watch TxWatchOptions
+}
+
+// DefaultTransactOptions returns the options used by Transact for the given
+// speed and confirmations.
+func DefaultTransactOptions(speed TxExecutionSpeed, confirmBlocks int64) TransactOptions {
+	return TransactOptions{
+		Speed:         speed,
+		ConfirmBlocks: confirmBlocks,
+	}.withDefaults()
+}
+
+// withDefaults returns a copy of the options with defaults for all unset
+// fields.
+func (options TransactOptions) withDefaults() TransactOptions {
+	if options.TxTimeout <= 0 {
+		options.TxTimeout = DefaultTxTimeout
+	}
+	if options.PostConditionTimeout <= 0 {
+		options.PostConditionTimeout = DefaultPostConditionTimeout
+	}
+	if options.Retry.InitialDelay <= 0 {
+		options.Retry.InitialDelay = DefaultRetryInitialDelay
+	}
+	if options.Retry.MaxDelay <= 0 {
+		options.Retry.MaxDelay = DefaultRetryMaxDelay
+	}
+	if options.Retry.Multiplier < 1 {
+		options.Retry.Multiplier = DefaultRetryMultiplier
+	}
+	watchDefaults := DefaultTxWatchOptions()
+	if options.Watch.PollInterval <= 0 {
+		options.Watch.PollInterval = watchDefaults.PollInterval
+	}
+	if options.Watch.MissingPolls <= 0 {
+		options.Watch.MissingPolls = watchDefaults.MissingPolls
+	}
+	if options.Watch.StuckAfter <= 0 {
+		options.Watch.StuckAfter = watchDefaults.StuckAfter
+	}
+	if options.Watch.MaxRebroadcasts == 0 {
+		options.Watch.MaxRebroadcasts = DefaultTxMaxRebroadcasts
+	} else if options.Watch.MaxRebroadcasts < 0 {
+		options.Watch.MaxRebroadcasts = 0
+	}
+	return options
+}
+
+// gasPrice returns the gas price capped at MaxGasPrice. The gas price can be
+// nil, in which case the ceiling is returned.
+func (options TransactOptions) gasPrice(gasPrice *big.Int) *big.Int {
+	if options.MaxGasPrice == nil || gasPrice != nil && gasPrice.Cmp(options.MaxGasPrice) <= 0 {
+		return gasPrice
+	}
+	return new(big.Int).Set(options.MaxGasPrice)
+}
+
+// transactor returns the transact opts for one attempt of a transaction, by
+// applying the options to the transact opts of the account. The caller sets
+// the context and the signer of the attempt.
+func (options TransactOptions) transactor(transactOpts *bind.TransactOpts, nonce *big.Int) *bind.TransactOpts {
+	transactor := &bind.TransactOpts{
+		From:     transactOpts.From,
+		Nonce:    nonce,
+		Value:    big.NewInt(0),
+		GasLimit: transactOpts.GasLimit,
+		GasPrice: options.gasPrice(transactOpts.GasPrice),
+	}
+	if transactor.GasPrice != nil {
+		transactor.GasPrice = new(big.Int).Set(transactor.GasPrice)
+	}
+	if options.Value != nil {
+		transactor.Value = new(big.Int).Set(options.Value)
+	}
+	if options.GasLimit != 0 {
+		transactor.GasLimit = options.GasLimit
+	}
+	return transactor
+}
@@ -0,0 +1,88 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("transact options", func() {
+
+	recipient := common.HexToAddress("0xb0b")
+
+	Context("when using the defaults", func() {
+		It("should set every field that has a default", func() {
+			options := libeth.DefaultTransactOptions(libeth.Fast, 2)
+			Expect(options.Speed).Should(Equal(libeth.Fast))
+			Expect(options.ConfirmBlocks).Should(Equal(int64(2)))
+			Expect(options.TxTimeout).Should(Equal(libeth.DefaultTxTimeout))
+			Expect(options.PostConditionTimeout).Should(Equal(libeth.DefaultPostConditionTimeout))
+			Expect(options.Retry).Should(Equal(libeth.RetryPolicy{
+				InitialDelay: libeth.DefaultRetryInitialDelay,
+				MaxDelay:     libeth.DefaultRetryMaxDelay,
+				Multiplier:   libeth.DefaultRetryMultiplier,
+			}))
+			Expect(options.Watch.PollInterval).Should(Equal(libeth.DefaultTxWatchOptions().PollInterval))
+			Expect(options.Watch.MissingPolls).Should(Equal(libeth.DefaultTxWatchOptions().MissingPolls))
+			Expect(options.Watch.MaxRebroadcasts).Should(Equal(libeth.DefaultTxMaxRebroadcasts))
+			Expect(options.Idempotent).Should(BeFalse())
+			Expect(options.Nonce).Should(BeNil())
+			Expect(options.Value).Should(BeNil())
+		})
+	})
+
+	Context("when transacting with options", func() {
+		// transact returns the transact opts of a transaction of a dry-run
+		// account with the options.
+		transact := func(options libeth.TransactOptions) *bind.TransactOpts {
+			server, _ := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			var opts *bind.TransactOpts
+			_, err = account.TransactWithOptions(ctx, func(tops *bind.TransactOpts) (*types.Transaction, error) {
+				opts = tops
+				return fakeTransfer(client, recipient, 1, nil)(tops)
+			}, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			return opts
+		}
+
+		It("should merge the options into the transact opts of the account", func() {
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Value = big.NewInt(5)
+			options.GasLimit = 50000
+			options.Nonce = big.NewInt(9)
+			options.MaxGasPrice = big.NewInt(2000000000)
+
+			opts := transact(options)
+			Expect(opts.Value).Should(Equal(big.NewInt(5)))
+			Expect(opts.GasLimit).Should(Equal(uint64(50000)))
+			Expect(opts.Nonce).Should(Equal(big.NewInt(9)))
+			Expect(opts.GasPrice.Cmp(options.MaxGasPrice)).Should(BeNumerically("<=", 0))
+		})
+
+		It("should use the defaults for fields that are not set", func() {
+			opts := transact(libeth.TransactOptions{})
+			Expect(opts.Value).Should(Equal(big.NewInt(0)))
+			Expect(opts.Nonce).Should(Equal(big.NewInt(0)))
+			Expect(opts.GasLimit).Should(BeZero())
+		})
+	})
+})
@@ -0,0 +1,336 @@
+package libeth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrPermitNotSupported indicates that a token does not implement EIP-2612.
+var ErrPermitNotSupported = errors.New("token does not support permit")
+
+// permitABIJSON is the ABI of the EIP-2612 extension of ERC20.
+const permitABIJSON = `[
+	{"constant":false,"inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"},{"name":"value","type":"uint256"},{"name":"deadline","type":"uint256"},{"name":"v","type":"uint8"},{"name":"r","type":"bytes32"},{"name":"s","type":"bytes32"}],"name":"permit","outputs":[],"payable":false,"stateMutability":"nonpayable","type":"function"},
+	{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"nonces","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"DOMAIN_SEPARATOR","outputs":[{"name":"","type":"bytes32"}],"payable":false,"stateMutability":"view","type":"function"},
+	{"constant":true,"inputs":[],"name":"PERMIT_TYPEHASH","outputs":[{"name":"","type":"bytes32"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
+
+var permitABI, _ = abi.JSON(strings.NewReader(permitABIJSON))
+
+// permitTypeHash is the type hash of an EIP-2612 permit. Tokens such as DAI
+// implement a permit with the same name and other fields.
+var permitTypeHash = crypto.Keccak256Hash([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+
+// permitTypes are the EIP-712 types of an EIP-2612 permit.
+var permitTypes = TypedDataTypes{
+	"Permit": {
+		{Name: "owner", Type: "address"},
+		{Name: "spender", Type: "address"},
+		{Name: "value", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+		{Name: "deadline", Type: "uint256"},
+	},
+}
+
+// Permit is a signed EIP-2612 approval of the spender by the owner, which
+// anyone can submit to the token before the deadline.
+type Permit struct {
+	Owner    common.Address
+	Spender  common.Address
+	Value    *big.Int
+	Nonce    *big.Int
+	Deadline *big.Int
+
+	V uint8
+	R [32]byte
+	S [32]byte
+}
+
+// SupportsPermit returns true if the token implements EIP-2612, which is
+// detected by calling its DOMAIN_SEPARATOR and nonces functions. Tokens such
+// as DAI have these functions but a different permit, so the PERMIT_TYPEHASH
+// of the token is compared to the one of EIP-2612 if the token exposes it.
+// Otherwise, a permit of nothing is signed with a throwaway key and
+// simulated, which succeeds only if the token has the EIP-2612 permit. The
+// signer of the account is never used, and the permit is never sent.
+func (erc20 *erc20) SupportsPermit(ctx context.Context) (bool, error) {
+	domainSeparator, err := erc20.domainSeparator(ctx)
+	if err != nil {
+		if err == ErrPermitNotSupported {
+			return false, nil
+		}
+		return false, err
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		return false, err
+	}
+	owner := crypto.PubkeyToAddress(key.PublicKey)
+	nonce, err := erc20.permitNonce(ctx, owner, nil)
+	if err != nil {
+		if err == ErrPermitNotSupported {
+			return false, nil
+		}
+		return false, err
+	}
+
+	typeHash, err := erc20.callPermit(ctx, nil, "PERMIT_TYPEHASH")
+	if err == nil {
+		return common.BytesToHash(typeHash) == permitTypeHash, nil
+	}
+	if err != ErrPermitNotSupported {
+		return false, err
+	}
+
+	deadline := big.NewInt(time.Now().Add(10 * time.Minute).Unix())
+	typedData := TypedData{
+		Types:       permitTypes,
+		PrimaryType: "Permit",
+		Message: map[string]interface{}{
+			"owner":    owner.Hex(),
+			"spender":  owner.Hex(),
+			"value":    "0",
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+	structHash, err := typedData.HashStruct("Permit", typedData.Message)
+	if err != nil {
+		return false, err
+	}
+	sig, err := crypto.Sign(crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator[:], structHash[:]), key)
+	if err != nil {
+		return false, err
+	}
+	if sig, err = SignatureWithV27(sig); err != nil {
+		return false, err
+	}
+	var r, s [32]byte
+	copy(r[:], sig[:32])
+	copy(s[:], sig[32:64])
+	data, err := permitABI.Pack("permit", owner, owner, big.NewInt(0), deadline, sig[64], r, s)
+	if err != nil {
+		return false, err
+	}
+	if _, err := erc20.client.EthClient().CallContract(ctx, ethereum.CallMsg{From: owner, To: &erc20.address, Data: data}, nil); err != nil {
+		if isRevert(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Permit signs an EIP-2612 permit that approves the value for the spender
+// from the account until the deadline (a unix timestamp). No transaction is
+// sent. The signature is made over the typed data of the permit if its domain
+// can be reconstructed from the token, so that wallets can display it, and
+// over the digest otherwise. Accounts with a spending policy only sign the
+// digest if the policy allows raw signing.
+func (erc20 *erc20) Permit(ctx context.Context, spender common.Address, value, deadline *big.Int) (Permit, error) {
+	owner := erc20.account.Address()
+	domainSeparator, err := erc20.domainSeparator(ctx)
+	if err != nil {
+		return Permit{}, err
+	}
+	nonce, err := erc20.permitNonce(ctx, owner, nil)
+	if err != nil {
+		return Permit{}, err
+	}
+
+	typedData := TypedData{
+		Types:       permitTypes,
+		PrimaryType: "Permit",
+		Message: map[string]interface{}{
+			"owner":    owner.Hex(),
+			"spender":  spender.Hex(),
+			"value":    value.String(),
+			"nonce":    nonce.String(),
+			"deadline": deadline.String(),
+		},
+	}
+
+	var sig []byte
+	if domain, ok := erc20.permitDomain(ctx, domainSeparator); ok {
+		typedData.Domain = domain
+		if sig, err = SignTypedData(ctx, erc20.account.Signer(), typedData); err != nil {
+			return Permit{}, err
+		}
+	} else {
+		structHash, err := typedData.HashStruct("Permit", typedData.Message)
+		if err != nil {
+			return Permit{}, err
+		}
+		digest := crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator[:], structHash[:])
+		if sig, err = erc20.account.Signer().SignHash(ctx, digest); err != nil {
+			return Permit{}, err
+		}
+		if sig, err = SignatureWithV27(sig); err != nil {
+			return Permit{}, err
+		}
+	}
+
+	permit := Permit{
+		Owner:    owner,
+		Spender:  spender,
+		Value:    new(big.Int).Set(value),
+		Nonce:    nonce,
+		Deadline: new(big.Int).Set(deadline),
+		V:        sig[64],
+	}
+	copy(permit.R[:], sig[:32])
+	copy(permit.S[:], sig[32:64])
+	return permit, nil
+}
+
+// SubmitPermit submits the permit from the account, as configured by the
+// options. The submission is only complete once the permit has been used,
+// which is when the allowance has changed or the nonce of the owner has moved
+// past the permit. The allowance is not required to equal the value of the
+// permit, since the spender can use it as soon as it is set.
+func (erc20 *erc20) SubmitPermit(ctx context.Context, permit Permit, options TransactOptions) (*types.Transaction, error) {
+	contract := bind.NewBoundContract(erc20.address, permitABI, erc20.client.EthClient(), erc20.client.EthClient(), erc20.client.EthClient())
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		condition, err := erc20.permitCondition(ctx, permit)
+		if err != nil {
+			return nil, err
+		}
+		options.PostCondition = condition
+	}
+	options.Idempotent = true
+
+	return erc20.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return contract.Transact(tops, "permit", permit.Owner, permit.Spender, permit.Value, permit.Deadline, permit.V, permit.R, permit.S)
+		},
+		options,
+	)
+}
+
+// PermitAndTransferFrom submits the permit and then transfers its value from
+// the owner to the recipient, both from the account, which has to be the
+// spender of the permit. It returns the transfer transaction.
+func (erc20 *erc20) PermitAndTransferFrom(ctx context.Context, permit Permit, to common.Address, options TransactOptions) (*types.Transaction, error) {
+	if permit.Spender != erc20.account.Address() {
+		return nil, ErrSignerAddressMismatch
+	}
+	permitOptions := options
+	permitOptions.PreCondition, permitOptions.PostCondition = nil, nil
+	permitOptions.PreConditionCheck, permitOptions.PostConditionCheck = nil, nil
+	if _, err := erc20.SubmitPermit(ctx, permit, permitOptions); err != nil {
+		return nil, err
+	}
+	return erc20.TransferFromWithOptions(ctx, permit.Owner, to, permit.Value, options)
+}
+
+// permitCondition returns a condition that passes once the permit has been
+// used, which is when the allowance of the spender differs from its current
+// value, or the nonce of the owner is past the nonce of the permit.
+func (erc20 *erc20) permitCondition(ctx context.Context, permit Permit) (Condition, error) {
+	allowance, err := erc20.Allowance(ctx, permit.Owner, permit.Spender)
+	if err != nil {
+		return nil, err
+	}
+	allowanceChanged := ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		current, err := erc20.bindings.Allowance(&bind.CallOpts{BlockNumber: block, Context: ctx}, permit.Owner, permit.Spender)
+		if err != nil {
+			return false, err
+		}
+		return current.Cmp(allowance) != 0, nil
+	})
+	nonceAdvanced := ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+		nonce, err := erc20.permitNonce(ctx, permit.Owner, block)
+		if err != nil {
+			return false, err
+		}
+		return permit.Nonce != nil && nonce.Cmp(permit.Nonce) > 0, nil
+	})
+	return DependsOn(Any(allowanceChanged, nonceAdvanced), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{erc20.address}}}}), nil
+}
+
+// permitDomain returns the EIP-712 domain of the token if it can be
+// reconstructed from its name, a common version and the chain ID, by
+// comparing it to the domain separator of the token.
+func (erc20 *erc20) permitDomain(ctx context.Context, domainSeparator common.Hash) (TypedDataDomain, bool) {
+	info, err := erc20.Info(ctx)
+	if err != nil {
+		return TypedDataDomain{}, false
+	}
+	chainID, err := erc20.client.EthClient().NetworkID(ctx)
+	if err != nil {
+		return TypedDataDomain{}, false
+	}
+	for _, version := range []string{"1", "2"} {
+		domain := TypedDataDomain{
+			Name:              info.Name,
+			Version:           version,
+			ChainID:           chainID,
+			VerifyingContract: &erc20.address,
+		}
+		if separator, err := (TypedData{Domain: domain}).DomainSeparator(); err == nil && separator == domainSeparator {
+			return domain, true
+		}
+	}
+	return TypedDataDomain{}, false
+}
+
+// domainSeparator returns the EIP-712 domain separator of the token.
+func (erc20 *erc20) domainSeparator(ctx context.Context) (common.Hash, error) {
+	resp, err := erc20.callPermit(ctx, nil, "DOMAIN_SEPARATOR")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(resp), nil
+}
+
+// permitNonce returns the permit nonce of the owner at the block, or at the
+// latest block if it is nil.
+func (erc20 *erc20) permitNonce(ctx context.Context, owner common.Address, block *big.Int) (*big.Int, error) {
+	resp, err := erc20.callPermit(ctx, block, "nonces", owner)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(resp), nil
+}
+
+// callPermit calls a view function of the permit extension at the block, and
+// returns its 32 byte result. Tokens that revert, or return anything else, do
+// not support permit.
+func (erc20 *erc20) callPermit(ctx context.Context, block *big.Int, method string, args ...interface{}) ([]byte, error) {
+	data, err := permitABI.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := erc20.client.EthClient().CallContract(ctx, ethereum.CallMsg{To: &erc20.address, Data: data}, block)
+	if err != nil {
+		if isRevert(err) {
+			return nil, ErrPermitNotSupported
+		}
+		return nil, err
+	}
+	if len(resp) != 32 || bytes.Equal(resp, make([]byte, 32)) && method != "nonces" {
+		return nil, ErrPermitNotSupported
+	}
+	return resp, nil
+}
+
+// isRevert returns true if the error of a call is caused by the contract
+// reverting or hitting an invalid opcode, rather than by the connection to the
+// node or any other failure of the call, such as a timeout.
+func isRevert(err error) bool {
+	return strings.Contains(err.Error(), "revert") || strings.Contains(err.Error(), "invalid opcode")
+}
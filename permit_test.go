@@ -0,0 +1,269 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+// refusingSigner signs transactions, but refuses to sign hashes and typed
+// data.
+type refusingSigner struct {
+	libeth.Signer
+}
+
+func (signer refusingSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return nil, errors.New("refused to sign hash")
+}
+
+func (signer refusingSigner) SignTypedData(ctx context.Context, typedData libeth.TypedData) ([]byte, error) {
+	return nil, errors.New("refused to sign typed data")
+}
+
+var _ = Describe("permits", func() {
+
+	token := common.HexToAddress("0x6b175474e89094c44da98b954eedeac495271d0f")
+	spender := common.HexToAddress("0x5e4d")
+
+	selector := func(signature string) []byte {
+		return crypto.Keccak256([]byte(signature))[:4]
+	}
+
+	permitTypes := libeth.TypedDataTypes{
+		"Permit": {
+			{Name: "owner", Type: "address"},
+			{Name: "spender", Type: "address"},
+			{Name: "value", Type: "uint256"},
+			{Name: "nonce", Type: "uint256"},
+			{Name: "deadline", Type: "uint256"},
+		},
+	}
+
+	// setup returns an ERC20 of a dry-run account against a token with the
+	// domain separator.
+	setup := func(domainSeparator common.Hash) (libeth.ERC20, libeth.Account, *FakeEth, func()) {
+		server, eth := newFakeNode()
+		client, err := libeth.Connect(libeth.Localnet, server.URL)
+		Expect(err).ShouldNot(HaveOccurred())
+		key, err := crypto.GenerateKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		eth.respond(token, selector("name()"), abiString("Dai Stablecoin"))
+		eth.respond(token, selector("symbol()"), abiString("DAI"))
+		eth.respond(token, selector("decimals()"), common.LeftPadBytes([]byte{18}, 32))
+		eth.respond(token, selector("totalSupply()"), common.LeftPadBytes([]byte{0}, 32))
+		eth.respond(token, selector("allowance(address,address)"), common.LeftPadBytes([]byte{0}, 32))
+		if domainSeparator != (common.Hash{}) {
+			eth.respond(token, selector("DOMAIN_SEPARATOR()"), domainSeparator.Bytes())
+			eth.respond(token, selector("nonces(address)"), common.LeftPadBytes([]byte{7}, 32))
+			eth.respond(token, selector("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)"), []byte{})
+		}
+
+		erc20, err := account.NewERC20(token.Hex())
+		Expect(err).ShouldNot(HaveOccurred())
+		return erc20, account, eth, server.Close
+	}
+
+	domain := libeth.TypedDataDomain{
+		Name:              "Dai Stablecoin",
+		Version:           "1",
+		ChainID:           big.NewInt(1),
+		VerifyingContract: &token,
+	}
+
+	Context("when checking for permit support", func() {
+		It("should detect the permit functions", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			erc20, _, _, closeServer := setup(common.Hash{})
+			supported, err := erc20.SupportsPermit(ctx)
+			closeServer()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeFalse())
+
+			erc20, _, _, closeServer = setup(common.HexToHash("0x1"))
+			defer closeServer()
+			supported, err = erc20.SupportsPermit(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeTrue())
+		})
+
+		It("should not detect a permit with other fields", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			erc20, _, eth, closeServer := setup(common.HexToHash("0x1"))
+			defer closeServer()
+
+			// DAI exposes the type hash of its own permit
+			daiTypeHash := crypto.Keccak256([]byte("Permit(address holder,address spender,uint256 nonce,uint256 expiry,bool allowed)"))
+			eth.respond(token, selector("PERMIT_TYPEHASH()"), daiTypeHash)
+			supported, err := erc20.SupportsPermit(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeFalse())
+
+			typeHash := crypto.Keccak256([]byte("Permit(address owner,address spender,uint256 value,uint256 nonce,uint256 deadline)"))
+			eth.respond(token, selector("PERMIT_TYPEHASH()"), typeHash)
+			supported, err = erc20.SupportsPermit(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeTrue())
+		})
+
+		It("should simulate the permit if the type hash is not exposed", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			erc20, _, eth, closeServer := setup(common.HexToHash("0x1"))
+			defer closeServer()
+
+			// A token without the EIP-2612 permit reverts the simulation
+			eth.mu.Lock()
+			delete(eth.responses, token.Hex()+hex.EncodeToString(selector("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)")))
+			eth.mu.Unlock()
+			supported, err := erc20.SupportsPermit(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeFalse())
+		})
+		It("should not sign the simulated permit with the signer of the account", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, refusingSigner{libeth.NewPrivateKeySigner(key)})
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.respond(token, selector("DOMAIN_SEPARATOR()"), common.HexToHash("0x1").Bytes())
+			eth.respond(token, selector("nonces(address)"), common.LeftPadBytes([]byte{0}, 32))
+			eth.respond(token, selector("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)"), []byte{})
+
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			supported, err := erc20.SupportsPermit(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(supported).Should(BeTrue())
+		})
+	})
+
+	Context("when signing a permit", func() {
+		It("should sign the typed data if the domain can be reconstructed", func() {
+			domainSeparator, err := libeth.TypedData{Domain: domain}.DomainSeparator()
+			Expect(err).ShouldNot(HaveOccurred())
+			erc20, account, _, closeServer := setup(domainSeparator)
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			permit, err := erc20.Permit(ctx, spender, big.NewInt(100), big.NewInt(2000000000))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(permit.Nonce).Should(Equal(big.NewInt(7)))
+
+			hash, err := libeth.TypedData{
+				Types:       permitTypes,
+				PrimaryType: "Permit",
+				Domain:      domain,
+				Message: map[string]interface{}{
+					"owner":    account.Address().Hex(),
+					"spender":  spender.Hex(),
+					"value":    "100",
+					"nonce":    "7",
+					"deadline": "2000000000",
+				},
+			}.SigningHash()
+			Expect(err).ShouldNot(HaveOccurred())
+			sig := append(append(permit.R[:], permit.S[:]...), permit.V)
+			Expect(libeth.Verify(account.Address(), hash[:], sig)).Should(BeTrue())
+		})
+
+		It("should sign the digest if the domain is unknown", func() {
+			domainSeparator := common.HexToHash("0x1234")
+			erc20, account, _, closeServer := setup(domainSeparator)
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			permit, err := erc20.Permit(ctx, spender, big.NewInt(100), big.NewInt(2000000000))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			structHash, err := libeth.TypedData{Types: permitTypes}.HashStruct("Permit", map[string]interface{}{
+				"owner":    account.Address().Hex(),
+				"spender":  spender.Hex(),
+				"value":    "100",
+				"nonce":    "7",
+				"deadline": "2000000000",
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			digest := crypto.Keccak256([]byte{0x19, 0x01}, domainSeparator[:], structHash[:])
+			sig := append(append(permit.R[:], permit.S[:]...), permit.V)
+			Expect(libeth.Verify(account.Address(), digest, sig)).Should(BeTrue())
+		})
+	})
+
+	Context("when submitting a permit", func() {
+		It("should be sent by the spender before the transfer", func() {
+			erc20, account, _, closeServer := setup(common.HexToHash("0x1"))
+			defer closeServer()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err := erc20.PermitAndTransferFrom(ctx, libeth.Permit{Spender: spender}, common.HexToAddress("0xb0b"), libeth.DefaultTransactOptions(libeth.Fast, 1))
+			Expect(err).Should(Equal(libeth.ErrSignerAddressMismatch))
+
+			_, err = erc20.SubmitPermit(ctx, libeth.Permit{
+				Owner:    common.HexToAddress("0xa11ce"),
+				Spender:  account.Address(),
+				Value:    big.NewInt(1),
+				Deadline: big.NewInt(2000000000),
+			}, libeth.DefaultTransactOptions(libeth.Fast, 1))
+			Expect(err).ShouldNot(HaveOccurred())
+			log := account.(libeth.DryRunAccount).Log()
+			Expect(log).Should(HaveLen(1))
+			Expect(log[0].Tx.Data()[:4]).Should(Equal(selector("permit(address,address,uint256,uint256,uint8,bytes32,bytes32)")))
+		})
+
+		It("should complete once the nonce has advanced, even if the allowance has been used", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The spender has already used the allowance when the permit is
+			// checked, so only the nonce shows that it was used
+			eth.respond(token, selector("allowance(address,address)"), common.LeftPadBytes([]byte{0}, 32))
+			eth.respond(token, selector("nonces(address)"), common.LeftPadBytes([]byte{8}, 32))
+			erc20, err := account.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.mineWhen(ctx, func() bool { return eth.pendingTx(0) != nil })
+
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.PostConditionTimeout = 2 * time.Second
+			_, err = erc20.SubmitPermit(ctx, libeth.Permit{
+				Owner:    common.HexToAddress("0xa11ce"),
+				Spender:  account.Address(),
+				Value:    big.NewInt(1),
+				Nonce:    big.NewInt(7),
+				Deadline: big.NewInt(2000000000),
+			}, options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(eth.minedTxs()).Should(HaveLen(1))
+		})
+	})
+})
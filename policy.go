@@ -0,0 +1,420 @@
+package libeth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// SpendingRule identifies the rule of a SpendingPolicy that a transaction
+// violates.
+type SpendingRule string
+
+// SpendingRule values.
+const (
+	RuleValue       = SpendingRule("value")
+	RuleWindowValue = SpendingRule("window value")
+	RuleRecipient   = SpendingRule("recipient")
+	RuleGasPrice    = SpendingRule("gas price")
+	RuleFee         = SpendingRule("fee")
+	RuleSignature   = SpendingRule("signature")
+)
+
+// SpendingPolicyError is returned instead of signing a transaction that
+// violates a SpendingPolicy.
+type SpendingPolicyError struct {
+	Rule      SpendingRule
+	Token     common.Address
+	Recipient common.Address
+	Limit     *big.Int
+	Value     *big.Int
+}
+
+func (err *SpendingPolicyError) Error() string {
+	if err.Rule == RuleRecipient {
+		return fmt.Sprintf("spending policy violated: recipient %v is not allowed", err.Recipient.Hex())
+	}
+	if err.Rule == RuleSignature {
+		return "spending policy violated: hashes and typed data other than permits can only be signed if the policy allows raw signing"
+	}
+	if err.Token != (common.Address{}) {
+		return fmt.Sprintf("spending policy violated: %v of token %v is %v, limit is %v", err.Rule, err.Token.Hex(), err.Value, err.Limit)
+	}
+	return fmt.Sprintf("spending policy violated: %v is %v, limit is %v", err.Rule, err.Value, err.Limit)
+}
+
+// SpendingLimit limits the value of a single transaction and the total value
+// of all transactions in the window of a SpendingPolicy. Nil limits are not
+// enforced.
+type SpendingLimit struct {
+	PerTx     *big.Int
+	PerWindow *big.Int
+}
+
+// SpendingPolicy restricts the transactions that a signer is allowed to sign.
+// Tokens and recipients are address book keys or hex addresses, and are
+// resolved when a transaction is signed. If several keys of TokenLimits
+// resolve to the same token, the lowest of their limits apply. ERC20 transfer, transferFrom and
+// approve calls are decoded, so that their amounts count towards the limits
+// of the token and their recipients (or spenders) must be allowed. The same
+// applies to permits (EIP-2612 and DAI) that are signed as typed data, whose
+// token is the verifying contract of their domain. The transactions that an
+// account signs for one operation, such as its retries and replacements, only
+// count once.
+type SpendingPolicy struct {
+	EthLimit    SpendingLimit
+	TokenLimits map[string]SpendingLimit
+
+	// Window is the rolling time window of the PerWindow limits. With a zero
+	// window, earlier spends are never counted, so PerWindow only limits a
+	// single transaction like PerTx.
+	Window time.Duration
+
+	// AllowedRecipients is the recipient allowlist. A nil allowlist allows all
+	// recipients.
+	AllowedRecipients []string
+
+	// MaxGasPrice and MaxFee are the maximum gas price, and gas price times
+	// gas limit, of a transaction.
+	MaxGasPrice *big.Int
+	MaxFee      *big.Int
+
+	// AllowRawSigning allows hashes, and typed data other than permits, to be
+	// signed. They are rejected by default, since they can authorise spends
+	// that the policy cannot check, such as the digest of a permit.
+	AllowRawSigning bool
+}
+
+// ERC20 function selectors that are decoded by the policy signer.
+var (
+	erc20TransferSelector     = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	erc20TransferFromSelector = []byte{0x23, 0xb8, 0x72, 0xdd}
+	erc20ApproveSelector      = []byte{0x09, 0x5e, 0xa7, 0xb3}
+)
+
+// spendKey identifies the operation that a spend belongs to, so that signing
+// another transaction for the same operation replaces its spends. Transactions
+// sent by an account belong to the operation that sent them, whatever their
+// nonce, and so do the transactions that replace them. Other transactions are
+// identified by their nonce, and permits by their token and nonce.
+type spendKey struct {
+	operation uint64
+	permit    common.Address
+	nonce     uint64
+}
+
+// spend is a value that has been signed by a transaction, or by a permit.
+type spend struct {
+	key    spendKey
+	tx     common.Hash
+	token  common.Address
+	amount *big.Int
+	time   time.Time
+}
+
+// operationKey and replacedKey are the context keys of the operation that a
+// transaction is signed for, and of the transaction that it replaces.
+type (
+	operationKey struct{}
+	replacedKey  struct{}
+)
+
+// operations counts the operations that have been started.
+var operations uint64
+
+// withOperation returns a context for signing the transactions of a new
+// operation.
+func withOperation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, operationKey{}, atomic.AddUint64(&operations, 1))
+}
+
+// withReplaced returns a context for signing a transaction that replaces the
+// transaction with the hash, and belongs to the same operation.
+func withReplaced(ctx context.Context, hash common.Hash) context.Context {
+	return context.WithValue(ctx, replacedKey{}, hash)
+}
+
+type policySigner struct {
+	Signer
+
+	mu          *sync.Mutex
+	policy      SpendingPolicy
+	addressBook AddressBook
+	spends      []spend
+}
+
+// NewPolicySigner returns a signer that enforces the spending policy before
+// delegating transactions and permits to the given signer. Other hashes and
+// typed data are only signed if the policy allows raw signing.
+func NewPolicySigner(signer Signer, policy SpendingPolicy, addressBook AddressBook) Signer {
+	return &policySigner{
+		Signer:      signer,
+		mu:          new(sync.Mutex),
+		policy:      policy,
+		addressBook: addressBook,
+	}
+}
+
+// NewAccountWithPolicy returns a user account for the provided signer which
+// enforces the spending policy on every transaction. Violations are returned
+// as a *SpendingPolicyError before the transaction is signed.
+func NewAccountWithPolicy(client Client, signer Signer, policy SpendingPolicy) (Account, error) {
+	return NewAccountWithSigner(client, NewPolicySigner(signer, policy, NetworkAddressBook(client.renNetwork)))
+}
+
+func (signer *policySigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	key := signer.txKey(ctx, tx)
+	spends, err := signer.check(key, tx)
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := signer.Signer.SignTx(ctx, txSigner, tx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range spends {
+		spends[i].tx = signedTx.Hash()
+	}
+	signer.record(key, spends)
+	return signedTx, nil
+}
+
+// txKey returns the key of the spends of the transaction, which is that of the
+// transaction that it replaces, the operation that it is signed for, or its
+// nonce. This function expects the caller to hold the signer's mutex.
+func (signer *policySigner) txKey(ctx context.Context, tx *types.Transaction) spendKey {
+	if replaced, ok := ctx.Value(replacedKey{}).(common.Hash); ok {
+		for _, spend := range signer.spends {
+			if spend.tx == replaced {
+				return spend.key
+			}
+		}
+	}
+	if operation, ok := ctx.Value(operationKey{}).(uint64); ok {
+		return spendKey{operation: operation}
+	}
+	return spendKey{nonce: tx.Nonce()}
+}
+
+func (signer *policySigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	if !signer.policy.AllowRawSigning {
+		return nil, &SpendingPolicyError{Rule: RuleSignature}
+	}
+	return signer.Signer.SignHash(ctx, hash)
+}
+
+func (signer *policySigner) SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error) {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	if typedData.PrimaryType != "Permit" {
+		if !signer.policy.AllowRawSigning {
+			return nil, &SpendingPolicyError{Rule: RuleSignature}
+		}
+		return signer.Signer.SignTypedData(ctx, typedData)
+	}
+
+	key, spends, err := signer.checkPermit(typedData)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signer.Signer.SignTypedData(ctx, typedData)
+	if err != nil {
+		return nil, err
+	}
+	signer.record(key, spends)
+	return sig, nil
+}
+
+// checkPermit returns the spends of an EIP-2612 or DAI permit, or an error if
+// it violates the policy. A DAI permit that allows the spender approves an
+// unlimited amount. This function expects the caller to hold the signer's
+// mutex.
+func (signer *policySigner) checkPermit(typedData TypedData) (spendKey, []spend, error) {
+	if typedData.Domain.VerifyingContract == nil {
+		return spendKey{}, nil, &SpendingPolicyError{Rule: RuleSignature}
+	}
+	token := *typedData.Domain.VerifyingContract
+	spender, err := typedDataAddress(typedData.Message["spender"])
+	if err != nil {
+		return spendKey{}, nil, err
+	}
+	nonce, err := typedDataInteger(typedData.Message["nonce"])
+	if err != nil {
+		return spendKey{}, nil, err
+	}
+	var amount *big.Int
+	if allowed, ok := typedData.Message["allowed"]; ok {
+		amount = big.NewInt(0)
+		if allowed == true || allowed == "true" {
+			amount = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+		}
+	} else if amount, err = typedDataInteger(typedData.Message["value"]); err != nil {
+		return spendKey{}, nil, err
+	}
+
+	if err := signer.checkRecipient(spender); err != nil {
+		return spendKey{}, nil, err
+	}
+	key := spendKey{permit: token, nonce: nonce.Uint64()}
+	spends, err := signer.checkSpend(key, token, amount, signer.tokenLimit(token))
+	return key, spends, err
+}
+
+// check returns the spends of the transaction with the key, or an error if it
+// violates the policy. This function expects the caller to hold the signer's
+// mutex.
+func (signer *policySigner) check(key spendKey, tx *types.Transaction) ([]spend, error) {
+	policy := signer.policy
+
+	if policy.MaxGasPrice != nil && tx.GasPrice().Cmp(policy.MaxGasPrice) > 0 {
+		return nil, &SpendingPolicyError{Rule: RuleGasPrice, Limit: policy.MaxGasPrice, Value: tx.GasPrice()}
+	}
+	fee := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+	if policy.MaxFee != nil && fee.Cmp(policy.MaxFee) > 0 {
+		return nil, &SpendingPolicyError{Rule: RuleFee, Limit: policy.MaxFee, Value: fee}
+	}
+
+	// Contract creations have no recipient and only spend their value
+	if tx.To() == nil {
+		return signer.checkSpend(key, common.Address{}, tx.Value(), policy.EthLimit)
+	}
+
+	spends := []spend{}
+	if tx.Value().Sign() > 0 {
+		if err := signer.checkRecipient(*tx.To()); err != nil {
+			return nil, err
+		}
+		ethSpends, err := signer.checkSpend(key, common.Address{}, tx.Value(), policy.EthLimit)
+		if err != nil {
+			return nil, err
+		}
+		spends = append(spends, ethSpends...)
+	}
+
+	recipient, amount, ok := decodeERC20Call(tx.Data())
+	if !ok {
+		return spends, nil
+	}
+	if err := signer.checkRecipient(recipient); err != nil {
+		return nil, err
+	}
+	tokenSpends, err := signer.checkSpend(key, *tx.To(), amount, signer.tokenLimit(*tx.To()))
+	if err != nil {
+		return nil, err
+	}
+	return append(spends, tokenSpends...), nil
+}
+
+// tokenLimit returns the limit of the token, which is empty if the policy
+// does not limit it. If several keys resolve to the token, the lowest of their
+// limits apply, so that the result does not depend on the order of the map.
+func (signer *policySigner) tokenLimit(token common.Address) SpendingLimit {
+	limit := SpendingLimit{}
+	for key, tokenLimit := range signer.policy.TokenLimits {
+		if signer.resolve(key) != token {
+			continue
+		}
+		limit.PerTx = minLimit(limit.PerTx, tokenLimit.PerTx)
+		limit.PerWindow = minLimit(limit.PerWindow, tokenLimit.PerWindow)
+	}
+	return limit
+}
+
+// minLimit returns the lower of two limits, where nil is no limit.
+func minLimit(a, b *big.Int) *big.Int {
+	if a == nil || b != nil && b.Cmp(a) < 0 {
+		return b
+	}
+	return a
+}
+
+// checkRecipient returns an error if the recipient is not allowed.
+func (signer *policySigner) checkRecipient(recipient common.Address) error {
+	if signer.policy.AllowedRecipients == nil {
+		return nil
+	}
+	for _, allowed := range signer.policy.AllowedRecipients {
+		if signer.resolve(allowed) == recipient {
+			return nil
+		}
+	}
+	return &SpendingPolicyError{Rule: RuleRecipient, Recipient: recipient}
+}
+
+// checkSpend returns the spend of the amount, or an error if the amount
+// exceeds the limit. Previous spends with the same key are not counted
+// towards the window, because the transaction or permit replaces them.
+func (signer *policySigner) checkSpend(key spendKey, token common.Address, amount *big.Int, limit SpendingLimit) ([]spend, error) {
+	if limit.PerTx != nil && amount.Cmp(limit.PerTx) > 0 {
+		return nil, &SpendingPolicyError{Rule: RuleValue, Token: token, Limit: limit.PerTx, Value: amount}
+	}
+	if limit.PerWindow != nil {
+		total := new(big.Int).Set(amount)
+		since := time.Now().Add(-signer.policy.Window)
+		for _, spend := range signer.spends {
+			if spend.token == token && spend.key != key && spend.time.After(since) {
+				total.Add(total, spend.amount)
+			}
+		}
+		if total.Cmp(limit.PerWindow) > 0 {
+			return nil, &SpendingPolicyError{Rule: RuleWindowValue, Token: token, Limit: limit.PerWindow, Value: total}
+		}
+	}
+	return []spend{{key: key, token: token, amount: amount, time: time.Now()}}, nil
+}
+
+// record replaces the spends of the key and forgets spends that have left
+// the window. This function expects the caller to hold the signer's mutex.
+func (signer *policySigner) record(key spendKey, spends []spend) {
+	since := time.Now().Add(-signer.policy.Window)
+	recorded := spends
+	for _, spend := range signer.spends {
+		if spend.key != key && spend.time.After(since) {
+			recorded = append(recorded, spend)
+		}
+	}
+	signer.spends = recorded
+}
+
+// resolve returns the address of an address book key, or the key as a hex
+// address.
+func (signer *policySigner) resolve(addressOrAlias string) common.Address {
+	if address, ok := signer.addressBook[addressOrAlias]; ok {
+		return address
+	}
+	return common.HexToAddress(addressOrAlias)
+}
+
+// decodeERC20Call returns the recipient (or spender) and amount of an ERC20
+// transfer, transferFrom or approve call.
+func decodeERC20Call(data []byte) (common.Address, *big.Int, bool) {
+	if len(data) < 4 {
+		return common.Address{}, nil, false
+	}
+	args := data[4:]
+	switch {
+	case bytes.Equal(data[:4], erc20TransferSelector), bytes.Equal(data[:4], erc20ApproveSelector):
+		if len(args) != 64 {
+			return common.Address{}, nil, false
+		}
+	case bytes.Equal(data[:4], erc20TransferFromSelector):
+		if len(args) != 96 {
+			return common.Address{}, nil, false
+		}
+		args = args[32:]
+	default:
+		return common.Address{}, nil, false
+	}
+	return common.BytesToAddress(args[:32]), new(big.Int).SetBytes(args[32:64]), true
+}
@@ -0,0 +1,234 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("spending policies", func() {
+
+	token := common.HexToAddress("0x408e41876cccdc0f92210600ef50372656052a38")
+	recipient := common.HexToAddress("0x1")
+
+	erc20Transfer := func(to common.Address, amount int64) []byte {
+		data := []byte{0xa9, 0x05, 0x9c, 0xbb}
+		data = append(data, common.LeftPadBytes(to.Bytes(), 32)...)
+		return append(data, common.LeftPadBytes(big.NewInt(amount).Bytes(), 32)...)
+	}
+
+	newSigner := func(policy libeth.SpendingPolicy) libeth.Signer {
+		key, err := crypto.GenerateKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		return libeth.NewPolicySigner(libeth.NewPrivateKeySigner(key), policy, libeth.MainnetAddressBook)
+	}
+
+	sign := func(signer libeth.Signer, nonce uint64, to common.Address, value int64, data []byte) error {
+		tx := types.NewTransaction(nonce, to, big.NewInt(value), 100000, big.NewInt(1000000000), data)
+		_, err := signer.SignTx(context.Background(), types.HomesteadSigner{}, tx)
+		return err
+	}
+
+	Context("when signing eth transfers", func() {
+		It("should enforce the per transaction and window limits", func() {
+			signer := newSigner(libeth.SpendingPolicy{
+				EthLimit: libeth.SpendingLimit{PerTx: big.NewInt(100), PerWindow: big.NewInt(150)},
+				Window:   time.Hour,
+			})
+
+			err := sign(signer, 0, recipient, 101, nil)
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			Expect(err.(*libeth.SpendingPolicyError).Rule).Should(Equal(libeth.RuleValue))
+
+			Expect(sign(signer, 0, recipient, 100, nil)).Should(Succeed())
+			err = sign(signer, 1, recipient, 100, nil)
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			Expect(err.(*libeth.SpendingPolicyError).Rule).Should(Equal(libeth.RuleWindowValue))
+
+			// Re-signing the same nonce replaces the previous spend
+			Expect(sign(signer, 0, recipient, 50, nil)).Should(Succeed())
+			Expect(sign(signer, 1, recipient, 100, nil)).Should(Succeed())
+		})
+
+		It("should enforce the gas price and fee limits", func() {
+			signer := newSigner(libeth.SpendingPolicy{MaxGasPrice: big.NewInt(1000000000), MaxFee: big.NewInt(50000000000000)})
+			err := sign(signer, 0, recipient, 1, nil)
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			Expect(err.(*libeth.SpendingPolicyError).Rule).Should(Equal(libeth.RuleFee))
+		})
+	})
+
+	Context("when signing token transfers", func() {
+		It("should resolve tokens and recipients through the address book", func() {
+			signer := newSigner(libeth.SpendingPolicy{
+				TokenLimits:       map[string]libeth.SpendingLimit{"REN": {PerTx: big.NewInt(10)}},
+				AllowedRecipients: []string{"DarknodePayment", recipient.Hex()},
+			})
+
+			Expect(sign(signer, 0, token, 0, erc20Transfer(recipient, 10))).Should(Succeed())
+			Expect(sign(signer, 1, token, 0, erc20Transfer(libeth.MainnetAddressBook["DarknodePayment"], 10))).Should(Succeed())
+
+			err := sign(signer, 2, token, 0, erc20Transfer(recipient, 11))
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			Expect(err.(*libeth.SpendingPolicyError).Token).Should(Equal(token))
+
+			err = sign(signer, 2, token, 0, erc20Transfer(common.HexToAddress("0x2"), 1))
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			Expect(err.(*libeth.SpendingPolicyError).Rule).Should(Equal(libeth.RuleRecipient))
+		})
+
+		It("should apply the lowest limit of the keys that resolve to the same token", func() {
+			signer := newSigner(libeth.SpendingPolicy{
+				TokenLimits: map[string]libeth.SpendingLimit{"REN": {PerTx: big.NewInt(10)}, token.Hex(): {PerTx: big.NewInt(20)}},
+			})
+			for nonce := uint64(0); nonce < 10; nonce++ {
+				err := sign(signer, nonce, token, 0, erc20Transfer(recipient, 11))
+				Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+				Expect(err.(*libeth.SpendingPolicyError).Limit).Should(Equal(big.NewInt(10)))
+			}
+		})
+	})
+
+	Context("when an account signs a transaction again with another nonce", func() {
+		It("should only count the spends of the operation once", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccountWithPolicy(client, libeth.NewPrivateKeySigner(key), libeth.SpendingPolicy{
+				EthLimit: libeth.SpendingLimit{PerWindow: big.NewInt(100)},
+				Window:   time.Hour,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Nonce 0 is used by another transaction, so the node rejects the
+			// first transaction after it has been signed, and the transfer is
+			// signed again with the next nonce
+			other, err := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(0), 21000, big.NewInt(1000000000), nil), types.HomesteadSigner{}, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(client.EthClient().SendTransaction(ctx, other)).Should(Succeed())
+			Expect(eth.mine()).Should(Equal(1))
+			attempts := 0
+			transfer := func(tops *bind.TransactOpts) (*types.Transaction, error) {
+				attempts++
+				if attempts == 1 {
+					tx := types.NewTransaction(tops.Nonce.Uint64(), recipient, big.NewInt(100), 21000, big.NewInt(1000000000), nil)
+					if _, err := tops.Signer(types.HomesteadSigner{}, tops.From, tx); err != nil {
+						return nil, err
+					}
+					return nil, core.ErrNonceTooLow
+				}
+				return fakeTransfer(client, recipient, 100, nil)(tops)
+			}
+			eth.mineWithLogs(ctx, 1)
+			_, err = account.TransactWithOptions(ctx, transfer, libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(eth.minedTxs()).Should(HaveLen(2))
+		})
+	})
+
+	Context("when the window is zero", func() {
+		It("should only limit single transactions", func() {
+			signer := newSigner(libeth.SpendingPolicy{
+				EthLimit: libeth.SpendingLimit{PerWindow: big.NewInt(150)},
+			})
+			Expect(sign(signer, 0, recipient, 100, nil)).Should(Succeed())
+			Expect(sign(signer, 1, recipient, 100, nil)).Should(Succeed())
+			Expect(sign(signer, 2, recipient, 151, nil)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when signing typed data and hashes", func() {
+		permit := func(spender common.Address, nonce, value int64) libeth.TypedData {
+			return libeth.TypedData{
+				Types: libeth.TypedDataTypes{
+					"Permit": {
+						{Name: "owner", Type: "address"},
+						{Name: "spender", Type: "address"},
+						{Name: "value", Type: "uint256"},
+						{Name: "nonce", Type: "uint256"},
+						{Name: "deadline", Type: "uint256"},
+					},
+				},
+				PrimaryType: "Permit",
+				Domain:      libeth.TypedDataDomain{Name: "Republic Token", ChainID: big.NewInt(1), VerifyingContract: &token},
+				Message: map[string]interface{}{
+					"owner":    common.HexToAddress("0xa11ce").Hex(),
+					"spender":  spender.Hex(),
+					"value":    big.NewInt(value).String(),
+					"nonce":    big.NewInt(nonce).String(),
+					"deadline": "2000000000",
+				},
+			}
+		}
+		rule := func(err error) libeth.SpendingRule {
+			Expect(err).Should(BeAssignableToTypeOf(&libeth.SpendingPolicyError{}))
+			return err.(*libeth.SpendingPolicyError).Rule
+		}
+
+		It("should apply the allowlist and token limits to permits", func() {
+			signer := newSigner(libeth.SpendingPolicy{
+				TokenLimits:       map[string]libeth.SpendingLimit{"REN": {PerTx: big.NewInt(100), PerWindow: big.NewInt(150)}},
+				Window:            time.Hour,
+				AllowedRecipients: []string{recipient.Hex()},
+			})
+			ctx := context.Background()
+
+			_, err := signer.SignTypedData(ctx, permit(common.HexToAddress("0x2"), 0, 1))
+			Expect(rule(err)).Should(Equal(libeth.RuleRecipient))
+			_, err = signer.SignTypedData(ctx, permit(recipient, 0, 101))
+			Expect(rule(err)).Should(Equal(libeth.RuleValue))
+
+			_, err = signer.SignTypedData(ctx, permit(recipient, 0, 100))
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = signer.SignTypedData(ctx, permit(recipient, 1, 100))
+			Expect(rule(err)).Should(Equal(libeth.RuleWindowValue))
+
+			// Re-signing the same permit nonce replaces the previous spend
+			_, err = signer.SignTypedData(ctx, permit(recipient, 0, 50))
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = signer.SignTypedData(ctx, permit(recipient, 1, 100))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// A DAI permit that allows the spender is unlimited
+			daiPermit := permit(recipient, 2, 0)
+			daiPermit.Message["allowed"] = true
+			_, err = signer.SignTypedData(ctx, daiPermit)
+			Expect(rule(err)).Should(Equal(libeth.RuleValue))
+		})
+
+		It("should only sign hashes and other typed data if raw signing is allowed", func() {
+			ctx := context.Background()
+			typedData := libeth.TypedData{}
+			Expect(json.Unmarshal([]byte(mailTypedData), &typedData)).Should(Succeed())
+			hash := crypto.Keccak256([]byte("message"))
+
+			signer := newSigner(libeth.SpendingPolicy{})
+			_, err := signer.SignHash(ctx, hash)
+			Expect(rule(err)).Should(Equal(libeth.RuleSignature))
+			_, err = signer.SignTypedData(ctx, typedData)
+			Expect(rule(err)).Should(Equal(libeth.RuleSignature))
+
+			signer = newSigner(libeth.SpendingPolicy{AllowRawSigning: true})
+			_, err = signer.SignHash(ctx, hash)
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = signer.SignTypedData(ctx, typedData)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+})
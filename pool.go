@@ -0,0 +1,436 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrEmptyAccountPool indicates that an account pool was created without any
+// accounts.
+var ErrEmptyAccountPool = errors.New("account pool has no accounts")
+
+// ErrNoAvailableAccount indicates that no account in the pool has enough
+// balance to submit the transaction.
+var ErrNoAvailableAccount = errors.New("no account in the pool has enough balance")
+
+// ErrSendAllFromPool indicates that sendAll was requested from an account
+// pool, which has no single balance to send.
+var ErrSendAllFromPool = errors.New("cannot send all from an account pool")
+
+// AccountPool spreads transactions across many funded accounts. Every
+// transaction is submitted by the least-busy account that has enough balance
+// for it.
+type AccountPool interface {
+
+	// Client that the pool is connected to.
+	Client() Client
+
+	// Accounts returns the accounts in the pool.
+	Accounts() []Account
+
+	// Balances returns the wei balance of every account in the pool.
+	Balances(ctx context.Context) (map[common.Address]*big.Int, error)
+
+	// Pin returns a pool that only contains the account assigned to the given
+	// key. The first time a key is pinned it is assigned to the least-busy
+	// account with enough balance, so that transactions which must be ordered
+	// with respect to each other are submitted by the same account.
+	Pin(ctx context.Context, key string) (AccountPool, error)
+
+	// Unpin removes the assignment of the given key to an account.
+	Unpin(key string)
+
+	// Transfer sends the specified value of Eth to the given address from an
+	// account that can afford it.
+	Transfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, confirmBlocks int64, sendAll bool) (*types.Transaction, error)
+
+	// Transact performs a write operation on the Ethereum blockchain from the
+	// least-busy account with at least the minimum balance of the pool. See
+	// Account.Transact.
+	Transact(ctx context.Context, speed TxExecutionSpeed, preConditionCheck func() bool, f func(*bind.TransactOpts) (*types.Transaction, error), postConditionCheck func() bool, confirmBlocks int64) (*types.Transaction, error)
+
+	// TransferWithOptions and TransactWithOptions are like Transfer and
+	// Transact, configured by the options. See Account.TransactWithOptions.
+	TransferWithOptions(ctx context.Context, to common.Address, value *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error)
+	TransactWithOptions(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error)
+
+	NewERC20(addressOrAlias string, options ...ContractOption) (ERC20, error)
+}
+
+// accountPoolState is shared by a pool and the pools pinned from it, so that
+// the number of in-flight transactions of an account is counted once.
+type accountPoolState struct {
+	mu       *sync.Mutex
+	inFlight map[common.Address]int
+	pins     map[string]Account
+}
+
+type accountPool struct {
+	client     Client
+	accounts   []Account
+	minBalance *big.Int
+	state      *accountPoolState
+}
+
+// NewAccountPool returns a pool of the provided accounts. An account is only
+// used for a transaction if its balance is at least minBalance, which can be
+// nil if there is no minimum.
+func NewAccountPool(client Client, minBalance *big.Int, accounts ...Account) (AccountPool, error) {
+	if len(accounts) == 0 {
+		return nil, ErrEmptyAccountPool
+	}
+	if minBalance == nil {
+		minBalance = big.NewInt(0)
+	}
+	return &accountPool{
+		client:     client,
+		accounts:   accounts,
+		minBalance: minBalance,
+		state: &accountPoolState{
+			mu:       new(sync.Mutex),
+			inFlight: map[common.Address]int{},
+			pins:     map[string]Account{},
+		},
+	}, nil
+}
+
+func (pool *accountPool) Client() Client {
+	return pool.client
+}
+
+func (pool *accountPool) Accounts() []Account {
+	accounts := make([]Account, len(pool.accounts))
+	copy(accounts, pool.accounts)
+	return accounts
+}
+
+func (pool *accountPool) Balances(ctx context.Context) (map[common.Address]*big.Int, error) {
+	balances := map[common.Address]*big.Int{}
+	for _, account := range pool.accounts {
+		balance, err := account.BalanceAt(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		balances[account.Address()] = balance
+	}
+	return balances, nil
+}
+
+func (pool *accountPool) Pin(ctx context.Context, key string) (AccountPool, error) {
+	pool.state.mu.Lock()
+	account, ok := pool.state.pins[key]
+	pool.state.mu.Unlock()
+
+	if !ok {
+		var err error
+		if account, err = pool.pick(ctx, pool.hasMinBalance); err != nil {
+			return nil, err
+		}
+		// Pinning does not send a transaction
+		pool.release(account)
+
+		// Another caller might have pinned the key in the meantime
+		pool.state.mu.Lock()
+		if pinned, ok := pool.state.pins[key]; ok {
+			account = pinned
+		} else {
+			pool.state.pins[key] = account
+		}
+		pool.state.mu.Unlock()
+	}
+
+	return &accountPool{
+		client:     pool.client,
+		accounts:   []Account{account},
+		minBalance: pool.minBalance,
+		state:      pool.state,
+	}, nil
+}
+
+func (pool *accountPool) Unpin(key string) {
+	pool.state.mu.Lock()
+	defer pool.state.mu.Unlock()
+
+	delete(pool.state.pins, key)
+}
+
+func (pool *accountPool) Transfer(ctx context.Context, to common.Address, value *big.Int, speed TxExecutionSpeed, confirmBlocks int64, sendAll bool) (*types.Transaction, error) {
+	return pool.TransferWithOptions(ctx, to, value, sendAll, DefaultTransactOptions(speed, confirmBlocks))
+}
+
+func (pool *accountPool) TransferWithOptions(ctx context.Context, to common.Address, value *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error) {
+	if sendAll {
+		return nil, ErrSendAllFromPool
+	}
+
+	return pool.transact(ctx, pool.canAfford(value, 21000, options), func(account Account) (*types.Transaction, error) {
+		return account.TransferWithOptions(ctx, to, value, false, options)
+	})
+}
+
+func (pool *accountPool) Transact(ctx context.Context, speed TxExecutionSpeed, preConditionCheck func() bool, f func(*bind.TransactOpts) (*types.Transaction, error), postConditionCheck func() bool, confirmBlocks int64) (*types.Transaction, error) {
+	options := DefaultTransactOptions(speed, confirmBlocks)
+	options.PreConditionCheck = preConditionCheck
+	options.PostConditionCheck = postConditionCheck
+	return pool.TransactWithOptions(ctx, f, options)
+}
+
+// TransactWithOptions executes the transaction from an account that can
+// afford its value and gas. The gas limit of the options is used if it is
+// set, otherwise that of a transfer, since the gas that 'f' needs is unknown.
+func (pool *accountPool) TransactWithOptions(ctx context.Context, f func(*bind.TransactOpts) (*types.Transaction, error), options TransactOptions) (*types.Transaction, error) {
+	gasLimit := options.GasLimit
+	if gasLimit == 0 {
+		gasLimit = 21000
+	}
+	value := options.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	return pool.transact(ctx, pool.canAfford(value, gasLimit, options), func(account Account) (*types.Transaction, error) {
+		return account.TransactWithOptions(ctx, f, options)
+	})
+}
+
+// NewERC20 returns an ERC20 whose write operations are sent from the accounts
+// of the pool. The contract is validated once, if the options ask for it.
+func (pool *accountPool) NewERC20(addressOrAlias string, options ...ContractOption) (ERC20, error) {
+	view, err := pool.client.NewERC20View(addressOrAlias, options...)
+	if err != nil {
+		return nil, err
+	}
+	tokens := map[common.Address]ERC20{}
+	for _, account := range pool.accounts {
+		token, err := account.NewERC20(addressOrAlias)
+		if err != nil {
+			return nil, err
+		}
+		tokens[account.Address()] = token
+	}
+	return &poolERC20{
+		ERC20View: view,
+		pool:      pool,
+		tokens:    tokens,
+	}, nil
+}
+
+// hasMinBalance returns true if the account has at least the minimum balance
+// of the pool.
+func (pool *accountPool) hasMinBalance(ctx context.Context, account Account) bool {
+	balance, err := account.BalanceAt(ctx, nil)
+	return err == nil && balance.Cmp(pool.minBalance) >= 0
+}
+
+// canAfford returns an eligibility check for accounts that have the minimum
+// balance of the pool, and can pay for the value and the gas limit at the
+// suggested gas price. The gas price is fetched once, on the first check, and
+// reused for every account that is checked during the selection.
+func (pool *accountPool) canAfford(value *big.Int, gasLimit uint64, options TransactOptions) func(context.Context, Account) bool {
+	var cost *big.Int
+	return func(ctx context.Context, account Account) bool {
+		if cost == nil {
+			cost = new(big.Int).Set(value)
+			gasPrice, err := SuggestedGasPrice(options.Speed)
+			if err != nil {
+				gasPrice = nil
+			}
+			if gasPrice = options.gasPrice(gasPrice); gasPrice != nil {
+				cost.Add(cost, new(big.Int).Mul(new(big.Int).SetUint64(gasLimit), gasPrice))
+			}
+		}
+		balance, err := account.BalanceAt(ctx, nil)
+		return err == nil && balance.Cmp(cost) >= 0 && balance.Cmp(pool.minBalance) >= 0
+	}
+}
+
+// transact picks an account that is eligible for a transaction and executes
+// 'f' with it, while the transaction counts as in-flight for that account.
+func (pool *accountPool) transact(ctx context.Context, eligible func(context.Context, Account) bool, f func(Account) (*types.Transaction, error)) (*types.Transaction, error) {
+	account, err := pool.pick(ctx, eligible)
+	if err != nil {
+		return nil, err
+	}
+	defer pool.release(account)
+
+	return f(account)
+}
+
+// pick reserves the least-busy account that is eligible, by counting a
+// transaction as in-flight for it, so that concurrent callers pick different
+// accounts. The caller has to release the account. Accounts with the same
+// number of in-flight transactions are tried in the order in which they were
+// added to the pool.
+func (pool *accountPool) pick(ctx context.Context, eligible func(context.Context, Account) bool) (Account, error) {
+	accounts := pool.Accounts()
+	tried := make([]bool, len(accounts))
+
+	for range accounts {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		// Eligibility is checked without holding the lock, because it usually
+		// requires a call to the Ethereum client
+		account := pool.reserve(accounts, tried)
+		if eligible(ctx, account) {
+			return account, nil
+		}
+		pool.release(account)
+	}
+	return nil, ErrNoAvailableAccount
+}
+
+// reserve counts a transaction as in-flight for the least-busy account that
+// has not been tried yet, and marks it as tried.
+func (pool *accountPool) reserve(accounts []Account, tried []bool) Account {
+	pool.state.mu.Lock()
+	defer pool.state.mu.Unlock()
+
+	least := -1
+	for i, account := range accounts {
+		if tried[i] {
+			continue
+		}
+		if least < 0 || pool.state.inFlight[account.Address()] < pool.state.inFlight[accounts[least].Address()] {
+			least = i
+		}
+	}
+	tried[least] = true
+	pool.state.inFlight[accounts[least].Address()]++
+	return accounts[least]
+}
+
+// release stops counting a transaction as in-flight for the account.
+func (pool *accountPool) release(account Account) {
+	pool.state.mu.Lock()
+	defer pool.state.mu.Unlock()
+
+	if pool.state.inFlight[account.Address()]--; pool.state.inFlight[account.Address()] <= 0 {
+		delete(pool.state.inFlight, account.Address())
+	}
+}
+
+// poolERC20 is an ERC20 whose write operations are spread across the
+// accounts of a pool.
+type poolERC20 struct {
+	ERC20View
+
+	pool   *accountPool
+	tokens map[common.Address]ERC20
+}
+
+func (erc20 *poolERC20) Transfer(ctx context.Context, to common.Address, amount *big.Int, speed TxExecutionSpeed, sendAll bool) (*types.Transaction, error) {
+	return erc20.TransferWithOptions(ctx, to, amount, sendAll, DefaultTransactOptions(speed, 1))
+}
+
+func (erc20 *poolERC20) TransferWithOptions(ctx context.Context, to common.Address, amount *big.Int, sendAll bool, options TransactOptions) (*types.Transaction, error) {
+	if sendAll {
+		return nil, ErrSendAllFromPool
+	}
+	return erc20.pool.transact(ctx, func(ctx context.Context, account Account) bool {
+		balance, err := erc20.BalanceOf(ctx, account.Address())
+		return err == nil && balance.Cmp(amount) >= 0 && erc20.pool.hasMinBalance(ctx, account)
+	}, func(account Account) (*types.Transaction, error) {
+		return erc20.tokens[account.Address()].TransferWithOptions(ctx, to, amount, false, options)
+	})
+}
+
+func (erc20 *poolERC20) TransferAmount(ctx context.Context, to common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error) {
+	value, err := tokenValue(ctx, erc20, amount)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.Transfer(ctx, to, value, speed, false)
+}
+
+// Approve approves the spender from the least-busy account of the pool. Use a
+// pinned pool to control which account grants the allowance.
+func (erc20 *poolERC20) Approve(ctx context.Context, spender common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return erc20.ApproveWithOptions(ctx, spender, amount, DefaultTransactOptions(speed, 1))
+}
+
+func (erc20 *poolERC20) ApproveWithOptions(ctx context.Context, spender common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error) {
+	return erc20.pool.transact(ctx, erc20.pool.hasMinBalance, func(account Account) (*types.Transaction, error) {
+		return erc20.tokens[account.Address()].ApproveWithOptions(ctx, spender, amount, options)
+	})
+}
+
+func (erc20 *poolERC20) ApproveAmount(ctx context.Context, spender common.Address, amount Amount, speed TxExecutionSpeed) (*types.Transaction, error) {
+	value, err := tokenValue(ctx, erc20, amount)
+	if err != nil {
+		return nil, err
+	}
+	return erc20.Approve(ctx, spender, value, speed)
+}
+
+// EnsureAllowance ensures the allowance of the spender from every account of
+// the pool, because any of them can be picked to use it. It returns the last
+// transaction that was sent.
+func (erc20 *poolERC20) EnsureAllowance(ctx context.Context, spender common.Address, min *big.Int, options AllowanceOptions) (*types.Transaction, error) {
+	var last *types.Transaction
+	for _, account := range erc20.pool.Accounts() {
+		tx, err := erc20.tokens[account.Address()].EnsureAllowance(ctx, spender, min, options)
+		if err != nil {
+			return last, err
+		}
+		if tx != nil {
+			last = tx
+		}
+	}
+	return last, nil
+}
+
+// Permit signs a permit from the least-busy account of the pool that holds
+// the value, which is the owner of the permit. Use a pinned pool to control
+// which account is the owner.
+func (erc20 *poolERC20) Permit(ctx context.Context, spender common.Address, value, deadline *big.Int) (Permit, error) {
+	account, err := erc20.pool.pick(ctx, func(ctx context.Context, account Account) bool {
+		balance, err := erc20.BalanceOf(ctx, account.Address())
+		return err == nil && balance.Cmp(value) >= 0
+	})
+	if err != nil {
+		return Permit{}, err
+	}
+	// Signing a permit does not send a transaction
+	erc20.pool.release(account)
+
+	return erc20.tokens[account.Address()].Permit(ctx, spender, value, deadline)
+}
+
+// SubmitPermit submits the permit from the least-busy account of the pool.
+func (erc20 *poolERC20) SubmitPermit(ctx context.Context, permit Permit, options TransactOptions) (*types.Transaction, error) {
+	return erc20.pool.transact(ctx, erc20.pool.hasMinBalance, func(account Account) (*types.Transaction, error) {
+		return erc20.tokens[account.Address()].SubmitPermit(ctx, permit, options)
+	})
+}
+
+// PermitAndTransferFrom uses the permit from the account of the pool that is
+// its spender.
+func (erc20 *poolERC20) PermitAndTransferFrom(ctx context.Context, permit Permit, to common.Address, options TransactOptions) (*types.Transaction, error) {
+	token, ok := erc20.tokens[permit.Spender]
+	if !ok {
+		return nil, ErrSignerAddressMismatch
+	}
+	return token.PermitAndTransferFrom(ctx, permit, to, options)
+}
+
+func (erc20 *poolERC20) TransferFrom(ctx context.Context, from, to common.Address, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return erc20.TransferFromWithOptions(ctx, from, to, amount, DefaultTransactOptions(speed, 1))
+}
+
+func (erc20 *poolERC20) TransferFromWithOptions(ctx context.Context, from, to common.Address, amount *big.Int, options TransactOptions) (*types.Transaction, error) {
+	return erc20.pool.transact(ctx, func(ctx context.Context, account Account) bool {
+		allowance, err := erc20.Allowance(ctx, from, account.Address())
+		return err == nil && allowance.Cmp(amount) >= 0 && erc20.pool.hasMinBalance(ctx, account)
+	}, func(account Account) (*types.Transaction, error) {
+		return erc20.tokens[account.Address()].TransferFromWithOptions(ctx, from, to, amount, options)
+	})
+}
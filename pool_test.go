@@ -0,0 +1,150 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("account pools", func() {
+
+	ether := big.NewInt(1000000000000000000)
+	recipient := common.HexToAddress("0xb0b")
+
+	// newPool returns a pool of dry-run accounts with the balances in ether.
+	newPool := func(eth *FakeEth, client libeth.Client, balances ...int64) (libeth.AccountPool, []libeth.DryRunAccount) {
+		accounts := []libeth.Account{}
+		dryRunAccounts := []libeth.DryRunAccount{}
+		for _, balance := range balances {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			eth.balances[account.Address()] = new(big.Int).Mul(big.NewInt(balance), ether)
+			accounts = append(accounts, account)
+			dryRunAccounts = append(dryRunAccounts, account)
+		}
+		pool, err := libeth.NewAccountPool(client, nil, accounts...)
+		Expect(err).ShouldNot(HaveOccurred())
+		return pool, dryRunAccounts
+	}
+
+	Context("when transactions are sent concurrently", func() {
+		It("should spread them across accounts", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			pool, _ := newPool(eth, client, 1, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Each transaction waits until both have started, or until it
+			// times out if both were given the same account
+			mu := new(sync.Mutex)
+			senders := map[common.Address]bool{}
+			started := sync.WaitGroup{}
+			started.Add(2)
+			f := func(opts *bind.TransactOpts) (*types.Transaction, error) {
+				mu.Lock()
+				senders[opts.From] = true
+				mu.Unlock()
+				started.Done()
+				waited := make(chan struct{})
+				go func() {
+					started.Wait()
+					close(waited)
+				}()
+				select {
+				case <-waited:
+				case <-time.After(2 * time.Second):
+				}
+				return fakeTransfer(client, recipient, 1, nil)(opts)
+			}
+
+			done := sync.WaitGroup{}
+			for i := 0; i < 2; i++ {
+				done.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer done.Done()
+					_, err := pool.TransactWithOptions(ctx, f, libeth.DefaultTransactOptions(libeth.Fast, 0))
+					Expect(err).ShouldNot(HaveOccurred())
+				}()
+			}
+			done.Wait()
+			Expect(senders).Should(HaveLen(2))
+		})
+	})
+
+	Context("when a transaction sends value", func() {
+		It("should only pick an account that can afford the value and gas", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			pool, accounts := newPool(eth, client, 1, 3)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Value = new(big.Int).Mul(big.NewInt(2), ether)
+			options.MaxGasPrice = big.NewInt(1000000000)
+			_, err = pool.TransactWithOptions(ctx, fakeTransfer(client, recipient, 2, nil), options)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(accounts[0].Log()).Should(BeEmpty())
+			Expect(accounts[1].Log()).Should(HaveLen(1))
+
+			// The gas cannot be paid for if the balance only covers the value
+			options.Value = new(big.Int).Mul(big.NewInt(3), ether)
+			_, err = pool.TransactWithOptions(ctx, fakeTransfer(client, recipient, 3, nil), options)
+			Expect(err).Should(Equal(libeth.ErrNoAvailableAccount))
+		})
+	})
+
+	Context("when a permit is signed", func() {
+		It("should sign it from an account that holds the value", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			pool, accounts := newPool(eth, client, 1, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// Only the second account holds tokens
+			token := common.HexToAddress("0x9e1")
+			selector := func(signature string) []byte {
+				return crypto.Keccak256([]byte(signature))[:4]
+			}
+			balanceOf := append(selector("balanceOf(address)"), common.LeftPadBytes(accounts[1].Address().Bytes(), 32)...)
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes([]byte{0}, 32))
+			eth.respondTo(token, balanceOf, common.LeftPadBytes([]byte{10}, 32))
+			eth.respond(token, selector("name()"), abiString("Token"))
+			eth.respond(token, selector("symbol()"), abiString("TKN"))
+			eth.respond(token, selector("decimals()"), common.LeftPadBytes([]byte{18}, 32))
+			eth.respond(token, selector("totalSupply()"), common.LeftPadBytes([]byte{10}, 32))
+			eth.respond(token, selector("DOMAIN_SEPARATOR()"), common.HexToHash("0x1").Bytes())
+			eth.respond(token, selector("nonces(address)"), common.LeftPadBytes([]byte{0}, 32))
+
+			erc20, err := pool.NewERC20(token.Hex())
+			Expect(err).ShouldNot(HaveOccurred())
+			permit, err := erc20.Permit(ctx, recipient, big.NewInt(5), big.NewInt(time.Now().Add(time.Hour).Unix()))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(permit.Owner).Should(Equal(accounts[1].Address()))
+
+			_, err = erc20.Permit(ctx, recipient, big.NewInt(20), big.NewInt(time.Now().Add(time.Hour).Unix()))
+			Expect(err).Should(Equal(libeth.ErrNoAvailableAccount))
+		})
+	})
+})
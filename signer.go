@@ -0,0 +1,421 @@
+package libeth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrSignerAddressMismatch indicates that a signer was asked to sign for, or
+// returned a signature from, an address other than its own.
+var ErrSignerAddressMismatch = errors.New("signer address does not match")
+
+// ErrUnsupportedTxSigner indicates that a signer cannot produce signatures for
+// the requested transaction signing scheme.
+var ErrUnsupportedTxSigner = errors.New("unsupported transaction signer")
+
+// RemoteSignerHashContentType is the content type used with account_signData
+// to request a signature over a raw 32 byte hash from a remote signer.
+const RemoteSignerHashContentType = "data/hash"
+
+// Signer signs transactions, hashes and typed data on behalf of a single
+// Ethereum address. Signatures are returned in the [R || S || V] format with
+// V being 0 or 1.
+type Signer interface {
+
+	// Address returns the Ethereum address of the signing key.
+	Address() common.Address
+
+	// SignTx signs the transaction using the given transaction signing
+	// scheme.
+	SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error)
+
+	// SignHash signs the given 32 byte hash.
+	SignHash(ctx context.Context, hash []byte) ([]byte, error)
+
+	// SignTypedData signs the EIP-712 digest of the given typed data.
+	SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error)
+}
+
+type privateKeySigner struct {
+	privateKey *ecdsa.PrivateKey
+	address    common.Address
+}
+
+// NewPrivateKeySigner returns a Signer that holds the private key in memory.
+func NewPrivateKeySigner(privateKey *ecdsa.PrivateKey) Signer {
+	return &privateKeySigner{
+		privateKey: privateKey,
+		address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+	}
+}
+
+func (signer *privateKeySigner) Address() common.Address {
+	return signer.address
+}
+
+func (signer *privateKeySigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	return types.SignTx(tx, txSigner, signer.privateKey)
+}
+
+func (signer *privateKeySigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, signer.privateKey)
+}
+
+func (signer *privateKeySigner) SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error) {
+	hash, err := typedData.SigningHash()
+	if err != nil {
+		return nil, err
+	}
+	return crypto.Sign(hash[:], signer.privateKey)
+}
+
+// KeystoreSigner is a Signer backed by an encrypted go-ethereum keystore
+// file. Decrypting the key runs scrypt, which is deliberately slow, so the key
+// is kept in memory once it has been decrypted until the signer is locked.
+type KeystoreSigner interface {
+	Signer
+
+	// Lock zeroes the decrypted key and removes it from memory. The key is
+	// decrypted again, using the passphrase, by the next signature.
+	Lock()
+}
+
+type keystoreSigner struct {
+	mu         *sync.Mutex
+	keyJSON    []byte
+	passphrase string
+	address    common.Address
+	key        *ecdsa.PrivateKey
+}
+
+// NewKeystoreSigner returns a KeystoreSigner backed by an encrypted
+// go-ethereum keystore file. The key is decrypted to check the passphrase and
+// stays in memory, together with the passphrase, until the signer is locked.
+func NewKeystoreSigner(path, passphrase string) (KeystoreSigner, error) {
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeystoreSignerFromJSON(keyJSON, passphrase)
+}
+
+// NewKeystoreSignerFromJSON returns a KeystoreSigner backed by the contents of
+// an encrypted go-ethereum keystore file.
+func NewKeystoreSignerFromJSON(keyJSON []byte, passphrase string) (KeystoreSigner, error) {
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return &keystoreSigner{
+		mu:         new(sync.Mutex),
+		keyJSON:    keyJSON,
+		passphrase: passphrase,
+		address:    key.Address,
+		key:        key.PrivateKey,
+	}, nil
+}
+
+func (signer *keystoreSigner) Address() common.Address {
+	return signer.address
+}
+
+func (signer *keystoreSigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	var signedTx *types.Transaction
+	return signedTx, signer.withKey(func(key *ecdsa.PrivateKey) (err error) {
+		signedTx, err = types.SignTx(tx, txSigner, key)
+		return
+	})
+}
+
+func (signer *keystoreSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	var sig []byte
+	return sig, signer.withKey(func(key *ecdsa.PrivateKey) (err error) {
+		sig, err = crypto.Sign(hash, key)
+		return
+	})
+}
+
+func (signer *keystoreSigner) SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error) {
+	hash, err := typedData.SigningHash()
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignHash(ctx, hash[:])
+}
+
+func (signer *keystoreSigner) Lock() {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	if signer.key != nil {
+		zeroKey(signer.key)
+		signer.key = nil
+	}
+}
+
+// withKey calls f with the key, which is decrypted first if the signer has
+// been locked.
+func (signer *keystoreSigner) withKey(f func(key *ecdsa.PrivateKey) error) error {
+	signer.mu.Lock()
+	defer signer.mu.Unlock()
+
+	if signer.key == nil {
+		key, err := keystore.DecryptKey(signer.keyJSON, signer.passphrase)
+		if err != nil {
+			return err
+		}
+		signer.key = key.PrivateKey
+	}
+	return f(signer.key)
+}
+
+// RemoteSignerTxArgs are the transaction arguments of account_signTransaction.
+type RemoteSignerTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice hexutil.Big     `json:"gasPrice"`
+	Value    hexutil.Big     `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     *hexutil.Bytes  `json:"data"`
+	ChainID  *hexutil.Big    `json:"chainId,omitempty"`
+}
+
+// RemoteSignerTxResult is the result of account_signTransaction.
+type RemoteSignerTxResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+type remoteSigner struct {
+	client  *rpc.Client
+	address common.Address
+	chainID *big.Int
+}
+
+// NewRemoteSigner returns a Signer that forwards all signing requests to a
+// remote signer speaking the Clef JSON-RPC protocol (account_list,
+// account_signTransaction, account_signData and account_signTypedData), so
+// that the key never enters this process. The chain ID is used for EIP-155
+// transaction signatures and can be nil if only Homestead signatures are
+// needed. Hashes are signed using account_signData with the
+// RemoteSignerHashContentType, which a stock Clef does not accept.
+// ErrSignerAddressMismatch is returned if the remote signer does not manage
+// the address.
+func NewRemoteSigner(ctx context.Context, url string, address common.Address, chainID *big.Int) (Signer, error) {
+	client, err := rpc.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []common.Address
+	if err := client.CallContext(ctx, &addresses, "account_list"); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("cannot list remote signer accounts: %v", err)
+	}
+	for _, addr := range addresses {
+		if addr == address {
+			return &remoteSigner{
+				client:  client,
+				address: address,
+				chainID: chainID,
+			}, nil
+		}
+	}
+	client.Close()
+	return nil, ErrSignerAddressMismatch
+}
+
+func (signer *remoteSigner) Address() common.Address {
+	return signer.address
+}
+
+func (signer *remoteSigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	args := RemoteSignerTxArgs{
+		From:     signer.address,
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: hexutil.Big(*tx.GasPrice()),
+		Value:    hexutil.Big(*tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+	}
+	data := hexutil.Bytes(tx.Data())
+	args.To = tx.To()
+	args.Data = &data
+
+	switch {
+	case txSigner.Equal(types.HomesteadSigner{}):
+	case signer.chainID != nil && txSigner.Equal(types.NewEIP155Signer(signer.chainID)):
+		args.ChainID = (*hexutil.Big)(signer.chainID)
+	default:
+		return nil, ErrUnsupportedTxSigner
+	}
+
+	var result RemoteSignerTxResult
+	if err := signer.client.CallContext(ctx, &result, "account_signTransaction", args, nil); err != nil {
+		return nil, err
+	}
+	signedTx := new(types.Transaction)
+	if err := rlp.DecodeBytes(result.Raw, signedTx); err != nil {
+		return nil, err
+	}
+
+	// Make sure the remote signer has neither modified the transaction nor
+	// signed it with a different key
+	if txSigner.Hash(signedTx) != txSigner.Hash(tx) {
+		return nil, fmt.Errorf("remote signer modified the transaction")
+	}
+	if from, err := types.Sender(txSigner, signedTx); err != nil || from != signer.address {
+		return nil, ErrSignerAddressMismatch
+	}
+	return signedTx, nil
+}
+
+func (signer *remoteSigner) SignHash(ctx context.Context, hash []byte) ([]byte, error) {
+	var sig hexutil.Bytes
+	if err := signer.client.CallContext(ctx, &sig, "account_signData", RemoteSignerHashContentType, signer.address, hexutil.Bytes(hash)); err != nil {
+		return nil, err
+	}
+	return signer.verify(hash, sig)
+}
+
+func (signer *remoteSigner) SignTypedData(ctx context.Context, typedData TypedData) ([]byte, error) {
+	hash, err := typedData.SigningHash()
+	if err != nil {
+		return nil, err
+	}
+	var sig hexutil.Bytes
+	if err := signer.client.CallContext(ctx, &sig, "account_signTypedData", signer.address, typedData); err != nil {
+		return nil, err
+	}
+	return signer.verify(hash[:], sig)
+}
+
+// verify normalises V of a signature returned by the remote signer to 0 or 1
+// and checks that it was produced by the expected address.
+func (signer *remoteSigner) verify(hash, sig []byte) ([]byte, error) {
+	sig, err := SignatureWithV0(sig)
+	if err != nil {
+		return nil, err
+	}
+	if !Verify(signer.address, hash, sig) {
+		return nil, ErrSignerAddressMismatch
+	}
+	return sig, nil
+}
+
+// RemoteSignerAPI serves the account namespace of the remote signer protocol
+// using local Signers.
+type RemoteSignerAPI struct {
+	signers map[common.Address]Signer
+}
+
+// NewRemoteSignerStub returns an HTTP handler that serves the protocol used by
+// NewRemoteSigner on behalf of the given signers. It can stand in for Clef
+// during development and testing, or run in a separate process that holds the
+// keys.
+func NewRemoteSignerStub(signers ...Signer) (http.Handler, error) {
+	api := &RemoteSignerAPI{
+		signers: make(map[common.Address]Signer, len(signers)),
+	}
+	for _, signer := range signers {
+		api.signers[signer.Address()] = signer
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("account", api); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// List returns the addresses of the signers.
+func (api *RemoteSignerAPI) List(ctx context.Context) ([]common.Address, error) {
+	addresses := make([]common.Address, 0, len(api.signers))
+	for address := range api.signers {
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// SignTransaction signs the transaction described by args.
+func (api *RemoteSignerAPI) SignTransaction(ctx context.Context, args RemoteSignerTxArgs, methodSelector *string) (*RemoteSignerTxResult, error) {
+	signer, err := api.signer(args.From)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if args.Data != nil {
+		data = *args.Data
+	}
+	var tx *types.Transaction
+	if args.To == nil {
+		tx = types.NewContractCreation(uint64(args.Nonce), args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), data)
+	} else {
+		tx = types.NewTransaction(uint64(args.Nonce), *args.To, args.Value.ToInt(), uint64(args.Gas), args.GasPrice.ToInt(), data)
+	}
+	var txSigner types.Signer = types.HomesteadSigner{}
+	if args.ChainID != nil {
+		txSigner = types.NewEIP155Signer(args.ChainID.ToInt())
+	}
+	signedTx, err := signer.SignTx(ctx, txSigner, tx)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := rlp.EncodeToBytes(signedTx)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteSignerTxResult{Raw: raw, Tx: signedTx}, nil
+}
+
+// SignData signs data of the given content type. Only raw hashes are
+// supported.
+func (api *RemoteSignerAPI) SignData(ctx context.Context, contentType string, address common.Address, data hexutil.Bytes) (hexutil.Bytes, error) {
+	if contentType != RemoteSignerHashContentType {
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+	signer, err := api.signer(address)
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignHash(ctx, data)
+}
+
+// SignTypedData signs the EIP-712 digest of the typed data.
+func (api *RemoteSignerAPI) SignTypedData(ctx context.Context, address common.Address, typedData TypedData) (hexutil.Bytes, error) {
+	signer, err := api.signer(address)
+	if err != nil {
+		return nil, err
+	}
+	return signer.SignTypedData(ctx, typedData)
+}
+
+func (api *RemoteSignerAPI) signer(address common.Address) (Signer, error) {
+	signer, ok := api.signers[address]
+	if !ok {
+		return nil, fmt.Errorf("unknown account %s", address.Hex())
+	}
+	return signer, nil
+}
+
+// zeroKey overwrites the private key in memory.
+func zeroKey(key *ecdsa.PrivateKey) {
+	b := key.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,193 @@
+package libeth_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+// contextSigner records the contexts that transactions are signed under.
+type contextSigner struct {
+	libeth.Signer
+	contexts []context.Context
+}
+
+func (signer *contextSigner) SignTx(ctx context.Context, txSigner types.Signer, tx *types.Transaction) (*types.Transaction, error) {
+	signer.contexts = append(signer.contexts, ctx)
+	return signer.Signer.SignTx(ctx, txSigner, tx)
+}
+
+type contextKey struct{}
+
+// mailTypedData is the example from the EIP-712 specification.
+const mailTypedData = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {
+		"name": "Ether Mail",
+		"version": "1",
+		"chainId": 1,
+		"verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+	},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+var _ = Describe("signers", func() {
+
+	typedData := func() libeth.TypedData {
+		var data libeth.TypedData
+		Expect(json.Unmarshal([]byte(mailTypedData), &data)).Should(Succeed())
+		return data
+	}
+
+	recoverAddress := func(hash, sig []byte) common.Address {
+		pubKey, err := crypto.SigToPub(hash, sig)
+		Expect(err).ShouldNot(HaveOccurred())
+		return crypto.PubkeyToAddress(*pubKey)
+	}
+
+	testSigner := func(signer libeth.Signer) {
+		ctx := context.Background()
+
+		hash := crypto.Keccak256([]byte("Message"))
+		sig, err := signer.SignHash(ctx, hash)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recoverAddress(hash, sig)).Should(Equal(signer.Address()))
+
+		data := typedData()
+		digest, err := data.SigningHash()
+		Expect(err).ShouldNot(HaveOccurred())
+		sig, err = signer.SignTypedData(ctx, data)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(recoverAddress(digest[:], sig)).Should(Equal(signer.Address()))
+
+		txSigner := types.NewEIP155Signer(big.NewInt(42))
+		tx := types.NewTransaction(1, common.HexToAddress("0x1"), big.NewInt(100), 21000, big.NewInt(1000000000), nil)
+		signedTx, err := signer.SignTx(ctx, txSigner, tx)
+		Expect(err).ShouldNot(HaveOccurred())
+		from, err := types.Sender(txSigner, signedTx)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(from).Should(Equal(signer.Address()))
+	}
+
+	Context("when hashing typed data", func() {
+		It("should match the EIP-712 specification", func() {
+			data := typedData()
+			domainSeparator, err := data.DomainSeparator()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(domainSeparator.Hex()).Should(Equal("0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"))
+			digest, err := data.SigningHash()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(digest.Hex()).Should(Equal("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"))
+		})
+	})
+
+	Context("when signing with a private key", func() {
+		It("should produce signatures that recover to the signer address", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := libeth.NewPrivateKeySigner(key)
+			Expect(signer.Address()).Should(Equal(crypto.PubkeyToAddress(key.PublicKey)))
+			testSigner(signer)
+		})
+	})
+
+	Context("when signing with a keystore file", func() {
+		It("should produce signatures that recover to the signer address", func() {
+			dir, err := ioutil.TempDir("", "libeth-keystore")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+			acc, err := ks.ImportECDSA(key, "passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = libeth.NewKeystoreSigner(acc.URL.Path, "wrong passphrase")
+			Expect(err).Should(HaveOccurred())
+
+			signer, err := libeth.NewKeystoreSigner(acc.URL.Path, "passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(signer.Address()).Should(Equal(acc.Address))
+			testSigner(signer)
+
+			// The key is decrypted again after the signer has been locked
+			signer.Lock()
+			testSigner(signer)
+		})
+	})
+
+	Context("when an account signs a transaction", func() {
+		It("should sign under the context of the transaction", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := &contextSigner{Signer: libeth.NewPrivateKeySigner(key)}
+			account, err := libeth.NewAccountWithSigner(client, signer)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), contextKey{}, "transaction"), 10*time.Second)
+			defer cancel()
+			eth.mineWhen(ctx, func() bool { return eth.pendingTx(0) != nil })
+			_, err = account.TransactWithOptions(ctx, fakeTransfer(client, common.HexToAddress("0xb0b"), 1, nil), libeth.DefaultTransactOptions(libeth.Fast, 0))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(signer.contexts).Should(HaveLen(1))
+			Expect(signer.contexts[0].Value(contextKey{})).Should(Equal("transaction"))
+		})
+	})
+
+	Context("when signing with a remote signer", func() {
+		It("should produce signatures that recover to the signer address", func() {
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			stub, err := libeth.NewRemoteSignerStub(libeth.NewPrivateKeySigner(key))
+			Expect(err).ShouldNot(HaveOccurred())
+			server := httptest.NewServer(stub)
+			defer server.Close()
+
+			_, err = libeth.NewRemoteSigner(context.Background(), server.URL, common.HexToAddress("0x1"), nil)
+			Expect(err).Should(Equal(libeth.ErrSignerAddressMismatch))
+
+			signer, err := libeth.NewRemoteSigner(context.Background(), server.URL, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(42))
+			Expect(err).ShouldNot(HaveOccurred())
+			testSigner(signer)
+		})
+	})
+})
@@ -0,0 +1,266 @@
+package libeth
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// TransferFilter selects the transfers delivered by SubscribeTransfers. Empty
+// from or to addresses match any address.
+type TransferFilter struct {
+	From []common.Address
+	To   []common.Address
+
+	// FromBlock is the first block whose transfers are delivered. A nil
+	// FromBlock starts after the latest confirmed block.
+	FromBlock *big.Int
+
+	// Confirmations is the number of blocks that have to be mined on top of
+	// the block of a transfer before it is delivered. Without confirmations,
+	// transfers are delivered as soon as they are seen by the WS client, if
+	// the client has one.
+	Confirmations uint64
+
+	// PollInterval is the time between two checks for new blocks when
+	// transfers are not received from the WS client. It defaults to one
+	// second.
+	PollInterval time.Duration
+
+	// Errors receives the errors that made the subscription reconnect. It
+	// can be nil, in which case the errors are dropped.
+	Errors chan<- error
+}
+
+// seenDepth is the number of blocks before the next block for which delivered
+// transfers are remembered, because the WS client can still send them after
+// they have been backfilled.
+const seenDepth = 128
+
+// eventKey identifies a log across backfills and subscriptions.
+type eventKey struct {
+	txHash   common.Hash
+	logIndex uint
+}
+
+// transferSubscription is the state of SubscribeTransfers that survives
+// reconnections.
+type transferSubscription struct {
+	erc20  *erc20
+	filter TransferFilter
+	sink   chan<- TransferEvent
+
+	// next is the first block that has not been fully delivered, and seen
+	// holds the block of every recently delivered transfer.
+	next uint64
+	seen map[eventKey]uint64
+}
+
+// SubscribeTransfers sends the transfers that match the filter to the sink,
+// in order and exactly once, until the context is done. Transfers are read
+// from the WS client when possible, and from paged log queries otherwise.
+// When the subscription fails, the error is sent to the Errors channel of the
+// filter, it reconnects with backoff, and the blocks since the last delivered
+// transfer are backfilled.
+func (erc20 *erc20) SubscribeTransfers(ctx context.Context, filter TransferFilter, sink chan<- TransferEvent) error {
+	if filter.PollInterval <= 0 {
+		filter.PollInterval = time.Second
+	}
+
+	sub := &transferSubscription{
+		erc20:  erc20,
+		filter: filter,
+		sink:   sink,
+		seen:   map[eventKey]uint64{},
+	}
+	if filter.FromBlock != nil {
+		sub.next = filter.FromBlock.Uint64()
+	} else {
+		err := erc20.client.Get(ctx, func() error {
+			safe, err := sub.safeBlock(ctx)
+			sub.next = safe + 1
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	delay := DefaultRetryInitialDelay
+	for {
+		next := sub.next
+		if err := sub.run(ctx); ctx.Err() != nil {
+			return ctx.Err()
+		} else if err != nil && filter.Errors != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case filter.Errors <- err:
+			}
+		}
+
+		// Reset the backoff if the subscription made progress before it
+		// failed
+		if sub.next > next {
+			delay = DefaultRetryInitialDelay
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = time.Duration(float64(delay) * DefaultRetryMultiplier)
+		if delay > DefaultRetryMaxDelay {
+			delay = DefaultRetryMaxDelay
+		}
+	}
+}
+
+// run delivers transfers until an error occurs or the context is done.
+func (sub *transferSubscription) run(ctx context.Context) error {
+	if err := sub.backfill(ctx); err != nil {
+		return err
+	}
+	if sub.erc20.client.ethWSClient != nil && sub.filter.Confirmations == 0 {
+		return sub.live(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(sub.filter.PollInterval):
+		}
+		if err := sub.backfill(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// live delivers transfers from the WS client. The blocks mined before the
+// subscription started are backfilled once it has started, so that no
+// transfer falls between the two.
+func (sub *transferSubscription) live(ctx context.Context) error {
+	wsBindings, err := bindings.NewERC20Detailed(sub.erc20.address, bind.ContractBackend(sub.erc20.client.ethWSClient))
+	if err != nil {
+		return err
+	}
+	transfers := make(chan *bindings.ERC20DetailedTransfer)
+	subscription, err := wsBindings.WatchTransfer(&bind.WatchOpts{Context: ctx}, transfers, sub.filter.From, sub.filter.To)
+	if err != nil {
+		return err
+	}
+	defer subscription.Unsubscribe()
+
+	if err := sub.backfill(ctx); err != nil {
+		return err
+	}
+
+	timestamps := newBlockTimestamps(sub.erc20.client)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-subscription.Err():
+			return err
+		case transfer := <-transfers:
+			if transfer.Raw.Removed {
+				continue
+			}
+			timestamp, err := timestamps.get(ctx, transfer.Raw.BlockNumber)
+			if err != nil {
+				return err
+			}
+			if err := sub.deliver(ctx, TransferEvent{
+				EventMeta: EventMeta{
+					BlockNumber: transfer.Raw.BlockNumber,
+					BlockHash:   transfer.Raw.BlockHash,
+					TxHash:      transfer.Raw.TxHash,
+					LogIndex:    transfer.Raw.Index,
+					Timestamp:   timestamp,
+				},
+				From:  transfer.From,
+				To:    transfer.To,
+				Value: transfer.Value,
+			}); err != nil {
+				return err
+			}
+			// Other transfers of the same block might still arrive, so the
+			// block is only marked as started
+			if transfer.Raw.BlockNumber > sub.next {
+				sub.next = transfer.Raw.BlockNumber
+				sub.prune()
+			}
+		}
+	}
+}
+
+// backfill delivers the transfers from the next block up to the latest
+// confirmed block.
+func (sub *transferSubscription) backfill(ctx context.Context) error {
+	safe, err := sub.safeBlock(ctx)
+	if err != nil {
+		return err
+	}
+	if sub.next > safe {
+		return nil
+	}
+
+	transfers, err := sub.erc20.Transfers(ctx, sub.filter.From, sub.filter.To, BlockRange{
+		From: new(big.Int).SetUint64(sub.next),
+		To:   new(big.Int).SetUint64(safe),
+	})
+	if err != nil {
+		return err
+	}
+	for _, transfer := range transfers {
+		if err := sub.deliver(ctx, transfer); err != nil {
+			return err
+		}
+	}
+
+	sub.next = safe + 1
+	sub.prune()
+	return nil
+}
+
+// prune forgets the delivered transfers that are too far behind the next
+// block to be sent again.
+func (sub *transferSubscription) prune() {
+	for key, block := range sub.seen {
+		if block+seenDepth < sub.next {
+			delete(sub.seen, key)
+		}
+	}
+}
+
+// deliver sends the transfer to the sink, unless it has already been sent.
+func (sub *transferSubscription) deliver(ctx context.Context, transfer TransferEvent) error {
+	key := eventKey{txHash: transfer.TxHash, logIndex: transfer.LogIndex}
+	if _, ok := sub.seen[key]; ok {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case sub.sink <- transfer:
+	}
+	sub.seen[key] = transfer.BlockNumber
+	return nil
+}
+
+// safeBlock returns the latest block with enough confirmations.
+func (sub *transferSubscription) safeBlock(ctx context.Context) (uint64, error) {
+	header, err := sub.erc20.client.ethClient.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	head := header.Number.Uint64()
+	if head < sub.filter.Confirmations {
+		return 0, nil
+	}
+	return head - sub.filter.Confirmations, nil
+}
@@ -0,0 +1,376 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/renproject/libeth-go/bindings"
+)
+
+// ErrBatchLengthMismatch indicates that the IDs and amounts of a batch
+// transfer have different lengths.
+var ErrBatchLengthMismatch = errors.New("batch ids and amounts have different lengths")
+
+type token1155 struct {
+	client   *Client
+	account  Account
+	address  common.Address
+	bindings *bindings.ERC1155
+}
+
+type Token1155 interface {
+	Token1155View
+
+	// SafeTransferFrom and SafeBatchTransferFrom transfer amounts of the IDs
+	// from their owner, which is either the account or has approved the
+	// account, to the recipient. Contracts that do not accept ERC1155 tokens
+	// reject the transfer.
+	SafeTransferFrom(ctx context.Context, from, to common.Address, id, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
+	SafeBatchTransferFrom(ctx context.Context, from, to common.Address, ids, amounts []*big.Int, speed TxExecutionSpeed) (*types.Transaction, error)
+	SetApprovalForAll(ctx context.Context, operator common.Address, approved bool, speed TxExecutionSpeed) (*types.Transaction, error)
+	SafeTransferFromWithOptions(ctx context.Context, from, to common.Address, id, amount *big.Int, data []byte, options TransactOptions) (*types.Transaction, error)
+	SafeBatchTransferFromWithOptions(ctx context.Context, from, to common.Address, ids, amounts []*big.Int, data []byte, options TransactOptions) (*types.Transaction, error)
+	SetApprovalForAllWithOptions(ctx context.Context, operator common.Address, approved bool, options TransactOptions) (*types.Transaction, error)
+}
+
+type Token1155View interface {
+	Address() common.Address
+	BalanceOf(ctx context.Context, owner common.Address, id *big.Int) (*big.Int, error)
+	BalanceOfBatch(ctx context.Context, owners []common.Address, ids []*big.Int) ([]*big.Int, error)
+	IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error)
+
+	// URI returns the metadata URI of the ID, with its {id} placeholder
+	// replaced as described by ResolveTokenURI, and Metadata fetches it.
+	URI(ctx context.Context, id *big.Int) (string, error)
+	Metadata(ctx context.Context, id *big.Int) (TokenMetadata, error)
+
+	// Transfers and ApprovalsForAll return the decoded events of the contract
+	// within the block range, with the timestamps of their blocks. Large
+	// ranges are queried in pages.
+	Transfers(ctx context.Context, operator, from, to []common.Address, blockRange BlockRange) ([]Token1155TransferEvent, error)
+	ApprovalsForAll(ctx context.Context, owner, operator []common.Address, blockRange BlockRange) ([]ApprovalForAllEvent, error)
+}
+
+// Token1155TransferEvent is a decoded ERC1155 transfer of a single ID. A
+// TransferBatch event is decoded as one transfer per ID, which share the
+// location of the event.
+type Token1155TransferEvent struct {
+	EventMeta
+	Operator common.Address
+	From     common.Address
+	To       common.Address
+	ID       *big.Int
+	Value    *big.Int
+}
+
+func (account *account) NewToken1155(addressOrAlias string, options ...ContractOption) (Token1155, error) {
+	address, err := account.client.resolveContract(account.addressBook, addressOrAlias, StandardERC1155, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC1155(address, bind.ContractBackend(account.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	client := account.Client()
+	return &token1155{
+		client:   &client,
+		account:  account,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+func (client *Client) NewToken1155View(addressOrAlias string, options ...ContractOption) (Token1155View, error) {
+	address, err := client.resolveContract(client.addrBook, addressOrAlias, StandardERC1155, options)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := bindings.NewERC1155(address, bind.ContractBackend(client.EthClient()))
+	if err != nil {
+		return nil, err
+	}
+	return &token1155{
+		client:   client,
+		address:  address,
+		bindings: bindings,
+	}, nil
+}
+
+func (token *token1155) Address() common.Address {
+	return token.address
+}
+
+func (token *token1155) BalanceOf(ctx context.Context, owner common.Address, id *big.Int) (*big.Int, error) {
+	var balance *big.Int
+	return balance, token.client.Get(ctx, func() (err error) {
+		balance, err = token.bindings.BalanceOf(&bind.CallOpts{Context: ctx}, owner, id)
+		return err
+	})
+}
+
+func (token *token1155) BalanceOfBatch(ctx context.Context, owners []common.Address, ids []*big.Int) ([]*big.Int, error) {
+	if len(owners) != len(ids) {
+		return nil, ErrBatchLengthMismatch
+	}
+	var balances []*big.Int
+	return balances, token.client.Get(ctx, func() (err error) {
+		balances, err = token.bindings.BalanceOfBatch(&bind.CallOpts{Context: ctx}, owners, ids)
+		return err
+	})
+}
+
+func (token *token1155) IsApprovedForAll(ctx context.Context, owner, operator common.Address) (bool, error) {
+	var approved bool
+	return approved, token.client.Get(ctx, func() (err error) {
+		approved, err = token.bindings.IsApprovedForAll(&bind.CallOpts{Context: ctx}, owner, operator)
+		return err
+	})
+}
+
+func (token *token1155) URI(ctx context.Context, id *big.Int) (string, error) {
+	var uri string
+	err := token.client.Get(ctx, func() (err error) {
+		uri, err = token.bindings.Uri(&bind.CallOpts{Context: ctx}, id)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return ResolveTokenURI(uri, id), nil
+}
+
+func (token *token1155) Metadata(ctx context.Context, id *big.Int) (TokenMetadata, error) {
+	uri, err := token.URI(ctx, id)
+	if err != nil {
+		return TokenMetadata{}, err
+	}
+	return fetchMetadata(ctx, uri)
+}
+
+func (token *token1155) SafeTransferFrom(ctx context.Context, from, to common.Address, id, amount *big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return token.SafeTransferFromWithOptions(ctx, from, to, id, amount, nil, DefaultTransactOptions(speed, 1))
+}
+
+// SafeTransferFromWithOptions transfers an amount of the ID with the data, as
+// configured by the options. See SafeBatchTransferFromWithOptions.
+func (token *token1155) SafeTransferFromWithOptions(ctx context.Context, from, to common.Address, id, amount *big.Int, data []byte, options TransactOptions) (*types.Transaction, error) {
+	options, err := token.transferOptions(from, to, []*big.Int{id}, []*big.Int{amount}, options)
+	if err != nil {
+		return nil, err
+	}
+	return token.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return token.bindings.SafeTransferFrom(tops, from, to, id, amount, data)
+		},
+		options,
+	)
+}
+
+func (token *token1155) SafeBatchTransferFrom(ctx context.Context, from, to common.Address, ids, amounts []*big.Int, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return token.SafeBatchTransferFromWithOptions(ctx, from, to, ids, amounts, nil, DefaultTransactOptions(speed, 1))
+}
+
+// SafeBatchTransferFromWithOptions transfers the amounts of the IDs with the
+// data, as configured by the options. The transfer is only sent while the
+// sender has the amounts, and unless the options have their own
+// post-condition, it is only complete once it has emitted a TransferSingle or
+// TransferBatch event to the recipient.
+func (token *token1155) SafeBatchTransferFromWithOptions(ctx context.Context, from, to common.Address, ids, amounts []*big.Int, data []byte, options TransactOptions) (*types.Transaction, error) {
+	options, err := token.transferOptions(from, to, ids, amounts, options)
+	if err != nil {
+		return nil, err
+	}
+	return token.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return token.bindings.SafeBatchTransferFrom(tops, from, to, ids, amounts, data)
+		},
+		options,
+	)
+}
+
+// transferOptions returns the options of a transfer of the amounts of the IDs
+// from one address to another. The pre-condition is that the sender has the
+// amounts, and the default post-condition is that the transaction emitted a
+// transfer event from the sender to the recipient, since the balances of the
+// recipient can be changed by other transfers at the same time.
+func (token *token1155) transferOptions(from, to common.Address, ids, amounts []*big.Int, options TransactOptions) (TransactOptions, error) {
+	if len(ids) != len(amounts) {
+		return options, ErrBatchLengthMismatch
+	}
+
+	// The same ID can appear more than once in a batch
+	totals := map[string]*big.Int{}
+	unique := []*big.Int{}
+	for i, id := range ids {
+		if _, ok := totals[id.String()]; !ok {
+			totals[id.String()] = new(big.Int)
+			unique = append(unique, id)
+		}
+		totals[id.String()].Add(totals[id.String()], amounts[i])
+	}
+
+	pre := []Condition{}
+	if options.PreCondition != nil {
+		pre = append(pre, options.PreCondition)
+	}
+	for _, id := range unique {
+		pre = append(pre, Token1155BalanceAtLeast(token.address, id, from, totals[id.String()]))
+	}
+	options.PreCondition = All(pre...)
+	options.Idempotent = true
+
+	if options.PostCondition != nil || options.PostConditionCheck != nil || from == to {
+		return options, nil
+	}
+	topics := []common.Hash{{}, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())}
+	options.PostCondition = Any(
+		receiptHasEvent(token.address, "TransferSingle(address,address,address,uint256,uint256)", topics...),
+		receiptHasEvent(token.address, "TransferBatch(address,address,address,uint256[],uint256[])", topics...),
+	)
+	return options, nil
+}
+
+func (token *token1155) SetApprovalForAll(ctx context.Context, operator common.Address, approved bool, speed TxExecutionSpeed) (*types.Transaction, error) {
+	return token.SetApprovalForAllWithOptions(ctx, operator, approved, DefaultTransactOptions(speed, 1))
+}
+
+// SetApprovalForAllWithOptions approves or revokes the operator for all IDs
+// of the account, as configured by the options. Unless the options have their
+// own post-condition, it is only complete once it has been set.
+func (token *token1155) SetApprovalForAllWithOptions(ctx context.Context, operator common.Address, approved bool, options TransactOptions) (*types.Transaction, error) {
+	owner := token.account.Address()
+	if options.PostCondition == nil && options.PostConditionCheck == nil {
+		options.PostCondition = DependsOn(ConditionFunc(func(ctx context.Context, client Client, block *big.Int, receipt *types.Receipt) (bool, error) {
+			isApproved, err := token.bindings.IsApprovedForAll(&bind.CallOpts{BlockNumber: block, Context: ctx}, owner, operator)
+			return isApproved == approved, err
+		}), ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token.address}}}})
+	}
+	options.Idempotent = true
+
+	return token.account.TransactWithOptions(
+		ctx,
+		func(tops *bind.TransactOpts) (*types.Transaction, error) {
+			return token.bindings.SetApprovalForAll(tops, operator, approved)
+		},
+		options,
+	)
+}
+
+// Transfers returns the single and batch transfers between the addresses
+// within the block range, oldest first. Empty addresses match any address.
+func (token *token1155) Transfers(ctx context.Context, operator, from, to []common.Address, blockRange BlockRange) ([]Token1155TransferEvent, error) {
+	events := []Token1155TransferEvent{}
+	err := token.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		page := []Token1155TransferEvent{}
+
+		singles, err := token.bindings.FilterTransferSingle(opts, operator, from, to)
+		if err != nil {
+			return err
+		}
+		defer singles.Close()
+		for singles.Next() {
+			page = append(page, Token1155TransferEvent{
+				EventMeta: eventMeta(singles.Event.Raw),
+				Operator:  singles.Event.Operator,
+				From:      singles.Event.From,
+				To:        singles.Event.To,
+				ID:        singles.Event.Id,
+				Value:     singles.Event.Value,
+			})
+		}
+		if err := singles.Error(); err != nil {
+			return err
+		}
+
+		batches, err := token.bindings.FilterTransferBatch(opts, operator, from, to)
+		if err != nil {
+			return err
+		}
+		defer batches.Close()
+		for batches.Next() {
+			for i, id := range batches.Event.Ids {
+				page = append(page, Token1155TransferEvent{
+					EventMeta: eventMeta(batches.Event.Raw),
+					Operator:  batches.Event.Operator,
+					From:      batches.Event.From,
+					To:        batches.Event.To,
+					ID:        id,
+					Value:     batches.Event.Values[i],
+				})
+			}
+		}
+		if err := batches.Error(); err != nil {
+			return err
+		}
+
+		// Both events are queried separately, so they are merged back into
+		// the order of the logs
+		sort.SliceStable(page, func(i, j int) bool {
+			if page[i].BlockNumber != page[j].BlockNumber {
+				return page[i].BlockNumber < page[j].BlockNumber
+			}
+			return page[i].LogIndex < page[j].LogIndex
+		})
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(token.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// ApprovalsForAll returns the approvals and revocations of operators within
+// the block range, oldest first. Empty addresses match any address.
+func (token *token1155) ApprovalsForAll(ctx context.Context, owner, operator []common.Address, blockRange BlockRange) ([]ApprovalForAllEvent, error) {
+	events := []ApprovalForAllEvent{}
+	err := token.client.filterPaged(ctx, blockRange, func(opts *bind.FilterOpts) error {
+		iter, err := token.bindings.FilterApprovalForAll(opts, owner, operator)
+		if err != nil {
+			return err
+		}
+		defer iter.Close()
+
+		page := []ApprovalForAllEvent{}
+		for iter.Next() {
+			page = append(page, ApprovalForAllEvent{
+				EventMeta: eventMeta(iter.Event.Raw),
+				Owner:     iter.Event.Account,
+				Operator:  iter.Event.Operator,
+				Approved:  iter.Event.Approved,
+			})
+		}
+		if err := iter.Error(); err != nil {
+			return err
+		}
+		events = append(events, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	timestamps := newBlockTimestamps(token.client)
+	for i := range events {
+		if events[i].Timestamp, err = timestamps.get(ctx, events[i].BlockNumber); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
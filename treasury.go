@@ -0,0 +1,216 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrInvalidTreasuryConfig indicates that a treasury cannot be created from
+// the given configuration.
+var ErrInvalidTreasuryConfig = errors.New("invalid treasury config")
+
+// TreasuryConfig defines the accounts that a Treasury manages and the
+// balances that it keeps them at.
+type TreasuryConfig struct {
+
+	// Funding is the account that tops up the managed accounts.
+	Funding Account
+
+	// ColdStorage is the address that balances above the ceilings are swept
+	// to.
+	ColdStorage common.Address
+
+	// Accounts are the managed accounts.
+	Accounts []Account
+
+	// MinBalance is the wei balance below which an account is topped up to
+	// TopUpBalance.
+	MinBalance   *big.Int
+	TopUpBalance *big.Int
+
+	// MaxBalance is the wei balance above which the surplus of an account is
+	// swept to cold storage. It can be nil, in which case Eth is not swept.
+	MaxBalance *big.Int
+
+	// TokenCeilings maps address book keys (or addresses) of ERC20 tokens to
+	// the balance above which the surplus of an account is swept to cold
+	// storage.
+	TokenCeilings map[string]*big.Int
+
+	// Interval is the time between two rebalances when the treasury is run.
+	Interval time.Duration
+
+	Speed         TxExecutionSpeed
+	ConfirmBlocks int64
+}
+
+// TreasuryReport lists the transactions of a rebalance, and the last error
+// that occurred while rebalancing, if any.
+type TreasuryReport struct {
+	TopUps []*types.Transaction
+	Sweeps []*types.Transaction
+	Err    error
+}
+
+// Treasury keeps the balances of a set of accounts between a minimum, by
+// topping them up from a funding account, and a ceiling, by sweeping the
+// surplus to cold storage.
+type Treasury struct {
+	config TreasuryConfig
+}
+
+// NewTreasury returns a treasury for the given configuration.
+func NewTreasury(config TreasuryConfig) (*Treasury, error) {
+	if config.Funding == nil || config.MinBalance == nil || config.TopUpBalance == nil {
+		return nil, fmt.Errorf("%v: funding account and balances are required", ErrInvalidTreasuryConfig)
+	}
+	if config.TopUpBalance.Cmp(config.MinBalance) < 0 {
+		return nil, fmt.Errorf("%v: top up balance is below the minimum balance", ErrInvalidTreasuryConfig)
+	}
+	if config.MaxBalance != nil && config.MaxBalance.Cmp(config.TopUpBalance) < 0 {
+		return nil, fmt.Errorf("%v: maximum balance is below the top up balance", ErrInvalidTreasuryConfig)
+	}
+	if (config.MaxBalance != nil || len(config.TokenCeilings) > 0) && config.ColdStorage == (common.Address{}) {
+		return nil, fmt.Errorf("%v: cold storage is required to sweep balances", ErrInvalidTreasuryConfig)
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Minute
+	}
+	return &Treasury{
+		config: config,
+	}, nil
+}
+
+// Run rebalances the accounts on every interval until the context is done,
+// and sends the reports that have transactions or an error to the channel.
+// Errors do not stop the treasury, so that one failing account does not
+// prevent the others from being rebalanced.
+func (treasury *Treasury) Run(ctx context.Context, reports chan<- TreasuryReport) error {
+	ticker := time.NewTicker(treasury.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		report, _ := treasury.Rebalance(ctx)
+		if (len(report.TopUps) > 0 || len(report.Sweeps) > 0 || report.Err != nil) && reports != nil {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case reports <- report:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Rebalance tops up and sweeps every account once. All accounts are
+// rebalanced even if some of them fail, in which case the last error is
+// returned along with the transactions that succeeded.
+func (treasury *Treasury) Rebalance(ctx context.Context) (TreasuryReport, error) {
+	report := TreasuryReport{}
+	var lastErr error
+
+	for _, account := range treasury.config.Accounts {
+		if account.Address() == treasury.config.Funding.Address() {
+			continue
+		}
+
+		tx, err := treasury.topUp(ctx, account)
+		if err != nil {
+			lastErr = fmt.Errorf("cannot top up %v: %v", account.Address().Hex(), err)
+		} else if tx != nil {
+			report.TopUps = append(report.TopUps, tx)
+		}
+
+		txs, err := treasury.sweep(ctx, account)
+		if err != nil {
+			lastErr = fmt.Errorf("cannot sweep %v: %v", account.Address().Hex(), err)
+		}
+		report.Sweeps = append(report.Sweeps, txs...)
+	}
+
+	report.Err = lastErr
+	return report, lastErr
+}
+
+// topUp transfers Eth from the funding account if the balance of the account
+// is below the minimum.
+func (treasury *Treasury) topUp(ctx context.Context, account Account) (*types.Transaction, error) {
+	balance, err := account.BalanceAt(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Cmp(treasury.config.MinBalance) >= 0 {
+		return nil, nil
+	}
+	value := new(big.Int).Sub(treasury.config.TopUpBalance, balance)
+	return treasury.config.Funding.Transfer(ctx, account.Address(), value, treasury.config.Speed, treasury.config.ConfirmBlocks, false)
+}
+
+// sweep transfers the surplus of every token above its ceiling, and then the
+// surplus of Eth above the maximum, to cold storage. Tokens are swept first,
+// so that the account still has Eth to pay for their gas.
+func (treasury *Treasury) sweep(ctx context.Context, account Account) ([]*types.Transaction, error) {
+	txs := []*types.Transaction{}
+
+	for token, ceiling := range treasury.config.TokenCeilings {
+		erc20, err := account.NewERC20(token)
+		if err != nil {
+			return txs, err
+		}
+		balance, err := erc20.BalanceOf(ctx, account.Address())
+		if err != nil {
+			return txs, err
+		}
+		if balance.Cmp(ceiling) <= 0 {
+			continue
+		}
+		tx, err := erc20.TransferWithOptions(ctx, treasury.config.ColdStorage, new(big.Int).Sub(balance, ceiling), false, DefaultTransactOptions(treasury.config.Speed, treasury.config.ConfirmBlocks))
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, tx)
+	}
+
+	if treasury.config.MaxBalance == nil {
+		return txs, nil
+	}
+	balance, err := account.BalanceAt(ctx, nil)
+	if err != nil {
+		return txs, err
+	}
+	if balance.Cmp(treasury.config.MaxBalance) <= 0 {
+		return txs, nil
+	}
+
+	// The gas of the sweep is paid from the surplus, so that the account is
+	// left with the maximum balance. The transfer is capped at the gas price
+	// that the value is computed with, so it never pays for more gas.
+	gasPrice, err := SuggestedGasPrice(treasury.config.Speed)
+	if err != nil {
+		if gasPrice, err = account.EthClient().SuggestGasPrice(ctx); err != nil {
+			return txs, err
+		}
+	}
+	value := new(big.Int).Sub(balance, treasury.config.MaxBalance)
+	value.Sub(value, new(big.Int).Mul(big.NewInt(21000), gasPrice))
+	if value.Sign() <= 0 {
+		return txs, nil
+	}
+	options := DefaultTransactOptions(treasury.config.Speed, treasury.config.ConfirmBlocks)
+	options.MaxGasPrice = gasPrice
+	tx, err := account.TransferWithOptions(ctx, treasury.config.ColdStorage, value, false, options)
+	if err != nil {
+		return txs, err
+	}
+	return append(txs, tx), nil
+}
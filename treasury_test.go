@@ -0,0 +1,147 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("treasuries", func() {
+
+	ether := big.NewInt(1000000000000000000)
+	coldStorage := common.HexToAddress("0xc01d")
+	token := common.HexToAddress("0x70ce")
+
+	etherValue := func(value int64) *big.Int {
+		return new(big.Int).Mul(big.NewInt(value), ether)
+	}
+
+	// newAccount returns a dry-run account with the balance in ether.
+	newAccount := func(eth *FakeEth, client libeth.Client, balance int64) libeth.DryRunAccount {
+		key, err := crypto.GenerateKey()
+		Expect(err).ShouldNot(HaveOccurred())
+		account, err := libeth.NewDryRunAccount(client, libeth.NewPrivateKeySigner(key))
+		Expect(err).ShouldNot(HaveOccurred())
+		eth.balances[account.Address()] = etherValue(balance)
+		return account
+	}
+
+	Context("when creating a treasury", func() {
+		It("should require cold storage to sweep balances", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			config := libeth.TreasuryConfig{
+				Funding:      newAccount(eth, client, 10),
+				MinBalance:   etherValue(1),
+				TopUpBalance: etherValue(2),
+			}
+			_, err = libeth.NewTreasury(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			config.TokenCeilings = map[string]*big.Int{token.Hex(): big.NewInt(100)}
+			_, err = libeth.NewTreasury(config)
+			Expect(err).Should(HaveOccurred())
+			config.TokenCeilings = nil
+			config.MaxBalance = etherValue(3)
+			_, err = libeth.NewTreasury(config)
+			Expect(err).Should(HaveOccurred())
+			config.ColdStorage = coldStorage
+			_, err = libeth.NewTreasury(config)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when rebalancing", func() {
+		It("should top up, sweep tokens above their ceiling and sweep eth above the maximum", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			selector := func(signature string) []byte {
+				return crypto.Keccak256([]byte(signature))[:4]
+			}
+			eth.respond(token, selector("balanceOf(address)"), common.LeftPadBytes(big.NewInt(150).Bytes(), 32))
+			eth.respond(token, selector("transfer(address,uint256)"), common.LeftPadBytes([]byte{1}, 32))
+
+			funding := newAccount(eth, client, 10)
+			poor := newAccount(eth, client, 0)
+			rich := newAccount(eth, client, 5)
+			treasury, err := libeth.NewTreasury(libeth.TreasuryConfig{
+				Funding:       funding,
+				ColdStorage:   coldStorage,
+				Accounts:      []libeth.Account{funding, poor, rich},
+				MinBalance:    etherValue(1),
+				TopUpBalance:  etherValue(2),
+				MaxBalance:    etherValue(3),
+				TokenCeilings: map[string]*big.Int{token.Hex(): big.NewInt(100)},
+				Speed:         libeth.Fast,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			report, err := treasury.Rebalance(ctx)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.TopUps).Should(HaveLen(1))
+			Expect(report.Sweeps).Should(HaveLen(3))
+
+			// The poor account is topped up to the top up balance
+			Expect(funding.Log()).Should(HaveLen(1))
+			topUp := funding.Log()[0].Tx
+			Expect(*topUp.To()).Should(Equal(poor.Address()))
+			Expect(topUp.Value()).Should(Equal(etherValue(2)))
+
+			// Both accounts sweep the surplus of the token
+			for _, account := range []libeth.DryRunAccount{poor, rich} {
+				sweep := account.Log()[0].Tx
+				Expect(*sweep.To()).Should(Equal(token))
+				Expect(common.BytesToAddress(sweep.Data()[4:36])).Should(Equal(coldStorage))
+				Expect(new(big.Int).SetBytes(sweep.Data()[36:68])).Should(Equal(big.NewInt(50)))
+			}
+
+			// The rich account keeps exactly the maximum balance after gas
+			Expect(rich.Log()).Should(HaveLen(2))
+			sweep := rich.Log()[1].Tx
+			Expect(*sweep.To()).Should(Equal(coldStorage))
+			spent := new(big.Int).Mul(new(big.Int).SetUint64(sweep.Gas()), sweep.GasPrice())
+			Expect(spent.Add(spent, sweep.Value())).Should(Equal(etherValue(2)))
+		})
+
+		It("should deliver reports to the channel when it is run", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The funding account cannot afford the top up
+			funding := newAccount(eth, client, 0)
+			treasury, err := libeth.NewTreasury(libeth.TreasuryConfig{
+				Funding:      funding,
+				Accounts:     []libeth.Account{newAccount(eth, client, 0)},
+				MinBalance:   etherValue(1),
+				TopUpBalance: etherValue(2),
+				Interval:     10 * time.Millisecond,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			reports := make(chan libeth.TreasuryReport)
+			go treasury.Run(ctx, reports)
+			var report libeth.TreasuryReport
+			Eventually(reports).Should(Receive(&report))
+			Expect(report.Err).Should(HaveOccurred())
+			Expect(report.TopUps).Should(BeEmpty())
+		})
+	})
+})
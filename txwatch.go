@@ -0,0 +1,288 @@
+package libeth
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrTxDropped indicates that a transaction disappeared from the mempool
+// without being mined.
+var ErrTxDropped = errors.New("transaction dropped")
+
+// ErrTxReplaced indicates that the nonce of a transaction was used by another
+// transaction.
+var ErrTxReplaced = errors.New("transaction replaced")
+
+// ErrTxStuck indicates that a transaction has been pending for too long with
+// a gas price below the suggested gas price.
+var ErrTxStuck = errors.New("transaction stuck")
+
+// TxStatus is the outcome of watching a transaction.
+type TxStatus uint8
+
+// TxStatus values.
+const (
+	TxMined = TxStatus(iota)
+	TxDropped
+	TxReplaced
+	TxStuck
+)
+
+// String implements the Stringer interface.
+func (status TxStatus) String() string {
+	switch status {
+	case TxMined:
+		return "mined"
+	case TxDropped:
+		return "dropped"
+	case TxReplaced:
+		return "replaced"
+	case TxStuck:
+		return "stuck"
+	default:
+		return "unknown"
+	}
+}
+
+// TxWatchResult is the outcome of watching a transaction. The receipt is only
+// set if the transaction was mined. Tx is the transaction that was mined, or
+// the last replacement that was sent, which differs from the watched
+// transaction if it was re-signed with a higher gas price. Rebroadcasts counts
+// the number of times the transaction was sent again or replaced while it was
+// watched.
+type TxWatchResult struct {
+	Status       TxStatus
+	Receipt      *types.Receipt
+	Tx           *types.Transaction
+	Rebroadcasts int
+}
+
+// Err returns nil if the transaction was mined, otherwise the error of its
+// status.
+func (result TxWatchResult) Err() error {
+	switch result.Status {
+	case TxDropped:
+		return ErrTxDropped
+	case TxReplaced:
+		return ErrTxReplaced
+	case TxStuck:
+		return ErrTxStuck
+	default:
+		return nil
+	}
+}
+
+// TxWatchOptions configure how a transaction is watched.
+type TxWatchOptions struct {
+
+	// PollInterval is the time between two checks of the transaction.
+	PollInterval time.Duration
+
+	// MissingPolls is the number of consecutive checks for which the
+	// transaction has to be missing from the node before it is considered
+	// dropped or replaced, so that propagation delays are not mistaken for
+	// either.
+	MissingPolls int
+
+	// StuckAfter is the time that a transaction can be pending before it is
+	// checked against the suggested gas price. A StuckAfter of zero disables
+	// the detection of stuck transactions.
+	StuckAfter time.Duration
+
+	// MaxRebroadcasts is the number of times that a dropped transaction is
+	// sent again, or that a stuck transaction is replaced, before its status
+	// is returned. Rebroadcasting is disabled by default.
+	MaxRebroadcasts int
+
+	// Resign signs the transaction again with the same nonce and the given
+	// gas price. Sending the same transaction again does not help a stuck
+	// transaction, so stuck transactions are only replaced if Resign is set.
+	Resign func(tx *types.Transaction, gasPrice *big.Int) (*types.Transaction, error)
+
+	// Superseded returns the transaction, with a different nonce, that has
+	// taken over from the transaction, or nil. The transaction that took over
+	// is watched instead, and the superseded transaction is never sent again.
+	Superseded func(tx *types.Transaction) *types.Transaction
+}
+
+// DefaultTxWatchOptions returns options that detect dropped and replaced
+// transactions, but not stuck transactions, and never rebroadcast. A
+// transaction has to be missing for a minute before it is considered dropped,
+// because load balanced nodes do not all know about pending transactions.
+func DefaultTxWatchOptions() TxWatchOptions {
+	return TxWatchOptions{
+		PollInterval: time.Second,
+		MissingPolls: 60,
+	}
+}
+
+// replacementGasPrice returns the lowest gas price that nodes accept for a
+// transaction replacing one with the given gas price, which is 10% higher.
+func replacementGasPrice(gasPrice *big.Int) *big.Int {
+	replacement := new(big.Int).Mul(gasPrice, big.NewInt(110))
+	replacement.Add(replacement, big.NewInt(99))
+	return replacement.Div(replacement, big.NewInt(100))
+}
+
+// WatchTx waits until the transaction is mined, dropped, replaced or stuck.
+// Unlike WaitMined, it can tell these cases apart from slow mining. An error
+// is only returned if the sender of the transaction cannot be recovered, or if
+// the context is done.
+func (client *Client) WatchTx(ctx context.Context, tx *types.Transaction, options TxWatchOptions) (TxWatchResult, error) {
+	if options.PollInterval <= 0 {
+		options.PollInterval = time.Second
+	}
+	if options.MissingPolls <= 0 {
+		options.MissingPolls = 1
+	}
+
+	from, err := types.Sender(txSigner(tx), tx)
+	if err != nil {
+		return TxWatchResult{}, err
+	}
+
+	// All versions of the transaction share its nonce, so at most one of them
+	// can be mined
+	txs := []*types.Transaction{tx}
+	result := TxWatchResult{Tx: tx}
+	pendingSince := time.Now()
+	missing := 0
+
+	for {
+		if receipt, mined := client.minedTx(ctx, txs); receipt != nil {
+			result.Status = TxMined
+			result.Receipt = receipt
+			result.Tx = mined
+			return result, nil
+		}
+		if options.Superseded != nil {
+			if next := options.Superseded(tx); next != nil {
+				tx = next
+				txs = []*types.Transaction{tx}
+				result.Tx = tx
+				pendingSince = time.Now()
+				missing = 0
+				continue
+			}
+		}
+
+		_, isPending, err := client.ethClient.TransactionByHash(ctx, tx.Hash())
+		switch {
+		case err == ethereum.NotFound:
+			missing++
+		case err == nil:
+			missing = 0
+		}
+
+		if missing >= options.MissingPolls {
+			// The nonce has been used if the account has mined a transaction
+			// with this nonce or a later one
+			nonce, err := client.ethClient.NonceAt(ctx, from, nil)
+			if err == nil && nonce > tx.Nonce() {
+				// The transaction might have been mined since its receipt was
+				// checked
+				if receipt, mined := client.minedTx(ctx, txs); receipt != nil {
+					result.Status = TxMined
+					result.Receipt = receipt
+					result.Tx = mined
+					return result, nil
+				}
+				result.Status = TxReplaced
+				return result, nil
+			}
+			if err == nil {
+				if result.Rebroadcasts >= options.MaxRebroadcasts {
+					result.Status = TxDropped
+					return result, nil
+				}
+				client.rebroadcast(ctx, tx)
+				result.Rebroadcasts++
+				missing = 0
+			}
+		}
+
+		if err == nil && isPending && options.StuckAfter > 0 && time.Since(pendingSince) >= options.StuckAfter {
+			gasPrice, err := client.ethClient.SuggestGasPrice(ctx)
+			if err == nil && tx.GasPrice().Cmp(gasPrice) < 0 {
+				if options.Resign == nil || result.Rebroadcasts >= options.MaxRebroadcasts {
+					result.Status = TxStuck
+					return result, nil
+				}
+				// Nodes only replace a pending transaction if the gas price
+				// of the replacement is at least 10% higher
+				if minGasPrice := replacementGasPrice(tx.GasPrice()); gasPrice.Cmp(minGasPrice) < 0 {
+					gasPrice = minGasPrice
+				}
+				replacement, err := options.Resign(tx, gasPrice)
+				if err != nil {
+					result.Status = TxStuck
+					return result, nil
+				}
+				if err := client.ethClient.SendTransaction(ctx, replacement); err == nil {
+					tx = replacement
+					txs = append(txs, tx)
+					result.Tx = tx
+					missing = 0
+				}
+				result.Rebroadcasts++
+				pendingSince = time.Now()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(options.PollInterval):
+		}
+	}
+}
+
+// minedTx returns the receipt of whichever of the transactions has been
+// mined, and the transaction itself, or nil if none of them has been mined.
+func (client *Client) minedTx(ctx context.Context, txs []*types.Transaction) (*types.Receipt, *types.Transaction) {
+	for _, tx := range txs {
+		if receipt, err := client.ethClient.TransactionReceipt(ctx, tx.Hash()); err == nil && receipt != nil {
+			return receipt, tx
+		}
+	}
+	return nil, nil
+}
+
+// rebroadcast sends a transaction again. Errors are ignored, because the node
+// might still know the transaction, and the watcher will notice if it does
+// not.
+func (client *Client) rebroadcast(ctx context.Context, tx *types.Transaction) {
+	_ = client.ethClient.SendTransaction(ctx, tx)
+}
+
+// txSigner returns the signing scheme of a signed transaction.
+func txSigner(tx *types.Transaction) types.Signer {
+	if tx.Protected() {
+		return types.NewEIP155Signer(tx.ChainId())
+	}
+	return types.HomesteadSigner{}
+}
+
+// previousTx returns the latest of the transactions that is either pending or
+// mined successfully, or nil if they have all been dropped, replaced or
+// reverted.
+func (client *Client) previousTx(ctx context.Context, txs []*types.Transaction) *types.Transaction {
+	for i := len(txs) - 1; i >= 0; i-- {
+		receipt, err := client.ethClient.TransactionReceipt(ctx, txs[i].Hash())
+		if err == nil && receipt != nil {
+			if receipt.Status == types.ReceiptStatusSuccessful {
+				return txs[i]
+			}
+			continue
+		}
+		if _, isPending, err := client.ethClient.TransactionByHash(ctx, txs[i].Hash()); err == nil && isPending {
+			return txs[i]
+		}
+	}
+	return nil
+}
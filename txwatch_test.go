@@ -0,0 +1,90 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("watching transactions", func() {
+
+	gwei := big.NewInt(1000000000)
+	recipient := common.HexToAddress("0xb0b")
+
+	Context("when a transaction is stuck", func() {
+		It("should replace it with a gas price at least 10% higher", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			account, err := libeth.NewAccount(client, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+			defer cancel()
+
+			// The fake node suggests 1 gwei, so a transaction at 0.5 gwei is
+			// stuck
+			eth.mineWhen(ctx, func() bool {
+				tx := eth.pendingTx(0)
+				return tx != nil && tx.GasPrice().Cmp(gwei) >= 0
+			})
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			options.Watch.PollInterval = 10 * time.Millisecond
+			options.Watch.StuckAfter = 50 * time.Millisecond
+			tx, err := account.TransactWithOptions(ctx, fakeTransfer(client, recipient, 1, big.NewInt(500000000)), options)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			mined := eth.minedTxs()
+			Expect(mined).Should(HaveLen(1))
+			Expect(tx.Hash()).Should(Equal(mined[0].Hash()))
+			Expect(tx.Nonce()).Should(Equal(uint64(0)))
+			Expect(tx.GasPrice()).Should(Equal(gwei))
+		})
+
+		It("should report it as stuck when it cannot be re-signed", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			tx, err := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(1), 21000, big.NewInt(500000000), nil), types.HomesteadSigner{}, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(client.EthClient().SendTransaction(ctx, tx)).Should(Succeed())
+
+			result, err := client.WatchTx(ctx, tx, libeth.TxWatchOptions{
+				PollInterval:    10 * time.Millisecond,
+				MissingPolls:    1,
+				StuckAfter:      50 * time.Millisecond,
+				MaxRebroadcasts: 3,
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(result.Status).Should(Equal(libeth.TxStuck))
+			Expect(result.Rebroadcasts).Should(Equal(0))
+			Expect(eth.pendingTx(0).Hash()).Should(Equal(tx.Hash()))
+		})
+	})
+
+	Context("when configuring how transactions are watched", func() {
+		It("should default to a long missing period and a few rebroadcasts", func() {
+			options := libeth.DefaultTransactOptions(libeth.Fast, 0)
+			Expect(options.Watch.PollInterval).Should(Equal(time.Second))
+			Expect(options.Watch.MissingPolls).Should(Equal(60))
+			Expect(options.Watch.StuckAfter).Should(BeZero())
+			Expect(options.Watch.MaxRebroadcasts).Should(Equal(libeth.DefaultTxMaxRebroadcasts))
+		})
+	})
+})
@@ -0,0 +1,399 @@
+package libeth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidTypedData indicates that the typed data cannot be encoded
+// according to EIP-712.
+var ErrInvalidTypedData = errors.New("invalid typed data")
+
+// TypedDataField is a single named member of an EIP-712 struct type.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedDataTypes maps EIP-712 struct type names to their members.
+type TypedDataTypes map[string][]TypedDataField
+
+// TypedDataDomain is the EIP-712 domain of a signature. Fields left empty are
+// not included in the domain separator.
+type TypedDataDomain struct {
+	Name              string          `json:"name,omitempty"`
+	Version           string          `json:"version,omitempty"`
+	ChainID           *big.Int        `json:"chainId,omitempty"`
+	VerifyingContract *common.Address `json:"verifyingContract,omitempty"`
+	Salt              *common.Hash    `json:"salt,omitempty"`
+}
+
+// TypedData is structured data that can be hashed and signed according to
+// EIP-712. It marshals to the same JSON layout that wallets and Clef accept.
+// Byte values in the message should be given as hexutil.Bytes or hex strings
+// so that they survive JSON encoding.
+type TypedData struct {
+	Types       TypedDataTypes         `json:"types"`
+	PrimaryType string                 `json:"primaryType"`
+	Domain      TypedDataDomain        `json:"domain"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// domainType is the name of the struct type used for the domain separator.
+const domainType = "EIP712Domain"
+
+var typedDataArrayRegexp = regexp.MustCompile(`^(.*)\[([0-9]*)\]$`)
+
+// UnmarshalJSON decodes the typed data without losing the precision of large
+// integers in the message.
+func (typedData *TypedData) UnmarshalJSON(data []byte) error {
+	type typedDataJSON TypedData
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode((*typedDataJSON)(typedData))
+}
+
+// SigningHash returns the EIP-712 digest of the typed data, which is the hash
+// that is passed to the signer.
+func (typedData TypedData) SigningHash() (common.Hash, error) {
+	domainSeparator, err := typedData.DomainSeparator()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	structHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte{0x19, 0x01}, domainSeparator[:], structHash[:]), nil
+}
+
+// DomainSeparator returns the hash of the domain. If the EIP712Domain type is
+// not declared in the types, it is derived from the domain fields that are
+// set.
+func (typedData TypedData) DomainSeparator() (common.Hash, error) {
+	types := typedData.types()
+	return types.hashStruct(domainType, typedData.Domain.message())
+}
+
+// HashStruct returns the EIP-712 hash of a message of the given struct type.
+func (typedData TypedData) HashStruct(primaryType string, message map[string]interface{}) (common.Hash, error) {
+	return typedData.types().hashStruct(primaryType, message)
+}
+
+// TypeHash returns the hash of the encoded struct type.
+func (typedData TypedData) TypeHash(primaryType string) (common.Hash, error) {
+	encodedType, err := typedData.types().encodeType(primaryType)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash([]byte(encodedType)), nil
+}
+
+// types returns the declared types along with the EIP712Domain type, which is
+// derived from the domain when it has not been declared.
+func (typedData TypedData) types() TypedDataTypes {
+	if _, ok := typedData.Types[domainType]; ok {
+		return typedData.Types
+	}
+	types := make(TypedDataTypes, len(typedData.Types)+1)
+	for name, fields := range typedData.Types {
+		types[name] = fields
+	}
+	types[domainType] = typedData.Domain.fields()
+	return types
+}
+
+// fields returns the EIP712Domain members for the domain fields that are set,
+// in the order defined by EIP-712.
+func (domain TypedDataDomain) fields() []TypedDataField {
+	fields := []TypedDataField{}
+	if domain.Name != "" {
+		fields = append(fields, TypedDataField{Name: "name", Type: "string"})
+	}
+	if domain.Version != "" {
+		fields = append(fields, TypedDataField{Name: "version", Type: "string"})
+	}
+	if domain.ChainID != nil {
+		fields = append(fields, TypedDataField{Name: "chainId", Type: "uint256"})
+	}
+	if domain.VerifyingContract != nil {
+		fields = append(fields, TypedDataField{Name: "verifyingContract", Type: "address"})
+	}
+	if domain.Salt != nil {
+		fields = append(fields, TypedDataField{Name: "salt", Type: "bytes32"})
+	}
+	return fields
+}
+
+func (domain TypedDataDomain) message() map[string]interface{} {
+	message := map[string]interface{}{}
+	if domain.Name != "" {
+		message["name"] = domain.Name
+	}
+	if domain.Version != "" {
+		message["version"] = domain.Version
+	}
+	if domain.ChainID != nil {
+		message["chainId"] = domain.ChainID
+	}
+	if domain.VerifyingContract != nil {
+		message["verifyingContract"] = *domain.VerifyingContract
+	}
+	if domain.Salt != nil {
+		message["salt"] = *domain.Salt
+	}
+	return message
+}
+
+func (types TypedDataTypes) hashStruct(primaryType string, message map[string]interface{}) (common.Hash, error) {
+	encoded, err := types.encodeData(primaryType, message)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return crypto.Keccak256Hash(encoded), nil
+}
+
+// encodeType returns the primary type followed by all of the struct types it
+// references, sorted by name.
+func (types TypedDataTypes) encodeType(primaryType string) (string, error) {
+	deps := map[string]struct{}{}
+	if err := types.dependencies(primaryType, deps); err != nil {
+		return "", err
+	}
+	delete(deps, primaryType)
+	sorted := make([]string, 0, len(deps))
+	for dep := range deps {
+		sorted = append(sorted, dep)
+	}
+	sort.Strings(sorted)
+
+	buf := new(bytes.Buffer)
+	for _, name := range append([]string{primaryType}, sorted...) {
+		members := make([]string, len(types[name]))
+		for i, field := range types[name] {
+			members[i] = fmt.Sprintf("%s %s", field.Type, field.Name)
+		}
+		fmt.Fprintf(buf, "%s(%s)", name, strings.Join(members, ","))
+	}
+	return buf.String(), nil
+}
+
+func (types TypedDataTypes) dependencies(primaryType string, deps map[string]struct{}) error {
+	if _, ok := deps[primaryType]; ok {
+		return nil
+	}
+	fields, ok := types[primaryType]
+	if !ok {
+		return fmt.Errorf("%v: unknown type %q", ErrInvalidTypedData, primaryType)
+	}
+	deps[primaryType] = struct{}{}
+	for _, field := range fields {
+		baseType := field.Type
+		for typedDataArrayRegexp.MatchString(baseType) {
+			baseType = typedDataArrayRegexp.FindStringSubmatch(baseType)[1]
+		}
+		if _, ok := types[baseType]; ok {
+			if err := types.dependencies(baseType, deps); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (types TypedDataTypes) encodeData(primaryType string, message map[string]interface{}) ([]byte, error) {
+	encodedType, err := types.encodeType(primaryType)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	buf.Write(crypto.Keccak256([]byte(encodedType)))
+	for _, field := range types[primaryType] {
+		value, ok := message[field.Name]
+		if !ok {
+			return nil, fmt.Errorf("%v: missing value for %s.%s", ErrInvalidTypedData, primaryType, field.Name)
+		}
+		encoded, err := types.encodeValue(field.Type, value)
+		if err != nil {
+			return nil, fmt.Errorf("%v: cannot encode %s.%s: %v", ErrInvalidTypedData, primaryType, field.Name, err)
+		}
+		buf.Write(encoded)
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeValue returns the 32 byte encoding of a value of the given type.
+func (types TypedDataTypes) encodeValue(typ string, value interface{}) ([]byte, error) {
+	// Arrays are encoded as the hash of their concatenated elements
+	if match := typedDataArrayRegexp.FindStringSubmatch(typ); match != nil {
+		elems := reflect.ValueOf(value)
+		if elems.Kind() != reflect.Slice && elems.Kind() != reflect.Array {
+			return nil, fmt.Errorf("expected array for %s, got %T", typ, value)
+		}
+		if match[2] != "" {
+			if length, _ := strconv.Atoi(match[2]); length != elems.Len() {
+				return nil, fmt.Errorf("expected %d elements for %s, got %d", length, typ, elems.Len())
+			}
+		}
+		buf := new(bytes.Buffer)
+		for i := 0; i < elems.Len(); i++ {
+			encoded, err := types.encodeValue(match[1], elems.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(encoded)
+		}
+		return crypto.Keccak256(buf.Bytes()), nil
+	}
+
+	// Structs are encoded as their hash
+	if _, ok := types[typ]; ok {
+		message, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected object for %s, got %T", typ, value)
+		}
+		hash, err := types.hashStruct(typ, message)
+		if err != nil {
+			return nil, err
+		}
+		return hash[:], nil
+	}
+
+	switch {
+	case typ == "string":
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %T", value)
+		}
+		return crypto.Keccak256([]byte(str)), nil
+
+	case typ == "bytes":
+		data, err := typedDataBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(data), nil
+
+	case typ == "bool":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected bool, got %T", value)
+		}
+		if b {
+			return math.PaddedBigBytes(big.NewInt(1), 32), nil
+		}
+		return make([]byte, 32), nil
+
+	case typ == "address":
+		address, err := typedDataAddress(value)
+		if err != nil {
+			return nil, err
+		}
+		return common.LeftPadBytes(address.Bytes(), 32), nil
+
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := strconv.Atoi(strings.TrimPrefix(typ, "bytes"))
+		if err != nil || size < 1 || size > 32 {
+			return nil, fmt.Errorf("unsupported type %s", typ)
+		}
+		data, err := typedDataBytes(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) != size {
+			return nil, fmt.Errorf("expected %d bytes for %s, got %d", size, typ, len(data))
+		}
+		return common.RightPadBytes(data, 32), nil
+
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		n, err := typedDataInteger(value)
+		if err != nil {
+			return nil, err
+		}
+		if n.Sign() < 0 && strings.HasPrefix(typ, "uint") {
+			return nil, fmt.Errorf("negative value for %s", typ)
+		}
+		return math.PaddedBigBytes(math.U256(new(big.Int).Set(n)), 32), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
+
+func typedDataBytes(value interface{}) ([]byte, error) {
+	switch value := value.(type) {
+	case []byte:
+		return value, nil
+	case hexutil.Bytes:
+		return value, nil
+	case common.Hash:
+		return value.Bytes(), nil
+	case [32]byte:
+		return value[:], nil
+	case string:
+		return hexutil.Decode(value)
+	default:
+		return nil, fmt.Errorf("expected bytes, got %T", value)
+	}
+}
+
+func typedDataAddress(value interface{}) (common.Address, error) {
+	switch value := value.(type) {
+	case common.Address:
+		return value, nil
+	case *common.Address:
+		return *value, nil
+	case string:
+		if !common.IsHexAddress(value) {
+			return common.Address{}, fmt.Errorf("invalid address %q", value)
+		}
+		return common.HexToAddress(value), nil
+	default:
+		return common.Address{}, fmt.Errorf("expected address, got %T", value)
+	}
+}
+
+func typedDataInteger(value interface{}) (*big.Int, error) {
+	switch value := value.(type) {
+	case *big.Int:
+		return value, nil
+	case big.Int:
+		return &value, nil
+	case int:
+		return big.NewInt(int64(value)), nil
+	case int64:
+		return big.NewInt(value), nil
+	case uint64:
+		return new(big.Int).SetUint64(value), nil
+	case float64:
+		if value != float64(int64(value)) {
+			return nil, fmt.Errorf("expected integer, got %v", value)
+		}
+		return big.NewInt(int64(value)), nil
+	case json.Number:
+		n, ok := new(big.Int).SetString(value.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("expected integer, got %v", value)
+		}
+		return n, nil
+	case string:
+		n, ok := math.ParseBig256(value)
+		if !ok {
+			return nil, fmt.Errorf("expected integer, got %q", value)
+		}
+		return n, nil
+	default:
+		return nil, fmt.Errorf("expected integer, got %T", value)
+	}
+}
@@ -0,0 +1,118 @@
+package libeth
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// WatchHeads sends the number of every new block to the channel until the
+// context is done. New heads are subscribed to through the WS client if the
+// client has one, otherwise (or if the subscription fails) the latest block is
+// polled every second.
+func (client *Client) WatchHeads(ctx context.Context, heads chan<- *big.Int) error {
+	if client.ethWSClient != nil {
+		headers := make(chan *types.Header)
+		sub, err := client.ethWSClient.SubscribeNewHead(ctx, headers)
+		if err == nil {
+			defer sub.Unsubscribe()
+
+		SubscriptionLoop:
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-sub.Err():
+					break SubscriptionLoop
+				case header := <-headers:
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case heads <- header.Number:
+					}
+				}
+			}
+		}
+	}
+
+	var last *big.Int
+	for {
+		header, err := client.ethClient.HeaderByNumber(ctx, nil)
+		if err == nil && (last == nil || header.Number.Cmp(last) > 0) {
+			last = header.Number
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case heads <- header.Number:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// WaitForCondition waits until the condition passes, or until the timeout
+// expires, in which case ErrPostConditionCheckFailed is returned. The
+// condition is first evaluated at the block of the receipt (or the latest
+// block if there is no receipt), and then re-evaluated at every new block
+// that is relevant to its dependencies. The blocks mined between the first
+// evaluation and the first new head are relevant too, so that the logs in
+// them are not missed.
+func (client *Client) WaitForCondition(ctx context.Context, condition Condition, receipt *types.Receipt, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	last, err := conditionBlock(ctx, *client, receipt)
+	if err == nil {
+		if ok, err := condition.Check(ctx, *client, last, receipt); err == nil && ok {
+			return nil
+		}
+	}
+
+	heads := make(chan *big.Int)
+	go client.WatchHeads(ctx, heads)
+
+	dependencies := DependenciesOf(condition)
+	for {
+		select {
+		case <-ctx.Done():
+			return ErrPostConditionCheckFailed
+		case head := <-heads:
+			from := head
+			if last != nil {
+				if head.Cmp(last) <= 0 {
+					continue
+				}
+				from = new(big.Int).Add(last, big.NewInt(1))
+			}
+			last = head
+			if !dependencies.NewHeads && !client.hasLogs(ctx, dependencies, from, head) {
+				continue
+			}
+			if ok, err := condition.Check(ctx, *client, head, receipt); err == nil && ok {
+				return nil
+			}
+		}
+	}
+}
+
+// hasLogs returns true if any log between the blocks (inclusive) matches the
+// dependencies. Errors are treated as a match, so that the condition is
+// evaluated rather than missed.
+func (client *Client) hasLogs(ctx context.Context, dependencies ConditionDependencies, from, to *big.Int) bool {
+	for _, query := range dependencies.Logs {
+		query.FromBlock = from
+		query.ToBlock = to
+		logs, err := client.ethClient.FilterLogs(ctx, query)
+		if err != nil || len(logs) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package libeth_test
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/renproject/libeth-go"
+)
+
+var _ = Describe("watching blocks", func() {
+
+	token := common.HexToAddress("0xbeef")
+
+	Context("when watching heads", func() {
+		It("should send every new head once", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			eth.mu.Lock()
+			eth.head = 5
+			eth.mu.Unlock()
+			heads := make(chan *big.Int)
+			done := make(chan error, 1)
+			go func() {
+				done <- client.WatchHeads(ctx, heads)
+			}()
+			Eventually(heads).Should(Receive(Equal(big.NewInt(5))))
+			Consistently(heads, 1500*time.Millisecond).ShouldNot(Receive())
+
+			eth.mu.Lock()
+			eth.head = 7
+			eth.mu.Unlock()
+			Eventually(heads, 3*time.Second).Should(Receive(Equal(big.NewInt(7))))
+
+			cancel()
+			Eventually(done).Should(Receive(Equal(context.Canceled)))
+		})
+	})
+
+	Context("when waiting for a condition", func() {
+		// afterBlock returns a condition on the logs of the token that passes
+		// from the block on.
+		afterBlock := func(block int64) libeth.Condition {
+			return libeth.DependsOn(libeth.ConditionFunc(func(ctx context.Context, client libeth.Client, at *big.Int, receipt *types.Receipt) (bool, error) {
+				return at.Cmp(big.NewInt(block)) >= 0, nil
+			}), libeth.ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+		}
+
+		It("should only re-evaluate the condition at blocks with relevant logs", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			mu := new(sync.Mutex)
+			evaluated := []int64{}
+			condition := libeth.DependsOn(libeth.ConditionFunc(func(ctx context.Context, client libeth.Client, at *big.Int, receipt *types.Receipt) (bool, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				evaluated = append(evaluated, at.Int64())
+				return at.Int64() >= 6, nil
+			}), libeth.ConditionDependencies{Logs: []ethereum.FilterQuery{{Addresses: []common.Address{token}}}})
+
+			// Block 6 has no logs of the token, and block 7 has one
+			eth.mu.Lock()
+			eth.head = 5
+			eth.mu.Unlock()
+			go func() {
+				time.Sleep(1200 * time.Millisecond)
+				eth.mu.Lock()
+				eth.head = 6
+				eth.mu.Unlock()
+				time.Sleep(1200 * time.Millisecond)
+				eth.mu.Lock()
+				eth.logs = append(eth.logs, types.Log{Address: token, BlockNumber: 7})
+				eth.head = 7
+				eth.mu.Unlock()
+			}()
+			Expect(client.WaitForCondition(ctx, condition, nil, 6*time.Second)).Should(Succeed())
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(evaluated).Should(Equal([]int64{5, 7}))
+		})
+
+		It("should not skip the blocks between the receipt and the first new head", func() {
+			server, eth := newFakeNode()
+			defer server.Close()
+			client, err := libeth.Connect(libeth.Localnet, server.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+			key, err := crypto.GenerateKey()
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			// The transaction is mined at block 1, and the log that passes
+			// the condition is in block 2, before the head at block 3
+			tx, err := types.SignTx(types.NewTransaction(0, common.HexToAddress("0xb0b"), big.NewInt(1), 21000, big.NewInt(1000000000), nil), types.HomesteadSigner{}, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(client.EthClient().SendTransaction(ctx, tx)).Should(Succeed())
+			Expect(eth.mine()).Should(Equal(1))
+			eth.mu.Lock()
+			eth.logs = append(eth.logs, types.Log{Address: token, BlockNumber: 2})
+			eth.head = 3
+			eth.mu.Unlock()
+
+			receipt := &types.Receipt{TxHash: tx.Hash()}
+			Expect(client.WaitForCondition(ctx, afterBlock(2), receipt, 3*time.Second)).Should(Succeed())
+		})
+	})
+})